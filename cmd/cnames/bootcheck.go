@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+var bootCheckConfig = dht.BootCheckCmdConfig{
+	Network:    dht.DefaultNetwork.String(),
+	Identity:   dht.DefaultIdentity,
+	MinHealthy: int64(dht.DefaultBootCheckMinHealthy),
+	Timeout:    dht.DefaultBootCheckTimeout,
+}
+
+var cmdBootCheck = &cli.Command{
+	Name:   "bootstrap-check",
+	Usage:  "resolves and dials each bootstrapper of the network, reporting its health",
+	Flags:  cmdBootCheckFlags,
+	Action: cmdBootCheckAction,
+}
+
+var cmdBootCheckFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name: "network",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NETWORK")},
+		},
+		Usage:       "celestia network whose bootstrappers will be checked",
+		Value:       bootCheckConfig.Network,
+		Destination: &bootCheckConfig.Network,
+	},
+	identityFlag(&bootCheckConfig.Identity),
+	bootstrapFlag(&bootCheckConfig.Bootstrap),
+	&cli.IntFlag{
+		Name: "min-healthy",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_BOOTCHECK_MIN_HEALTHY")},
+		},
+		Usage:       "exit with an error if fewer bootstrappers than this are healthy",
+		Value:       bootCheckConfig.MinHealthy,
+		Destination: &bootCheckConfig.MinHealthy,
+	},
+	&cli.DurationFlag{
+		Name: "timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_BOOTCHECK_TIMEOUT")},
+		},
+		Usage:       "time to resolve, or to dial and identify, a single address",
+		Value:       bootCheckConfig.Timeout,
+		Destination: &bootCheckConfig.Timeout,
+	},
+}
+
+func cmdBootCheckAction(ctx context.Context, cmd *cli.Command) error {
+	log.WithFields(log.Fields{
+		"network":     bootCheckConfig.Network,
+		"min-healthy": bootCheckConfig.MinHealthy,
+		"timeout":     bootCheckConfig.Timeout,
+	}).Info("starting cnames-bootstrap-check...")
+
+	if bootCheckConfig.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", bootCheckConfig.Timeout)
+	}
+
+	netConf, err := networkRegistry.Get(bootCheckConfig.Network)
+	if err != nil {
+		return err
+	}
+	privKey, err := loadIdentity(bootCheckConfig.Identity)
+	if err != nil {
+		return err
+	}
+	client, err := dht.NewClient(netConf, dht.WithIdentity(privKey))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// the --bootstrap entries, if any, are checked instead of the ones of the network
+	checks, err := client.CheckBootstrappers(ctx, bootCheckConfig.Bootstrap, bootCheckConfig.Timeout)
+	if err != nil {
+		return err
+	}
+
+	healthy := 0
+	for _, check := range checks {
+		printBootstrapCheck(check)
+		if check.Healthy() {
+			healthy++
+		}
+	}
+	log.Infof("healthy bootstrappers: %d/%d", healthy, len(checks))
+
+	if int64(healthy) < bootCheckConfig.MinHealthy {
+		return fmt.Errorf("only %d of %d bootstrappers are healthy (min %d)", healthy, len(checks), bootCheckConfig.MinHealthy)
+	}
+	return nil
+}
+
+func printBootstrapCheck(check *dht.BootstrapCheck) {
+	status := "unhealthy"
+	if check.Healthy() {
+		status = "healthy"
+	}
+	log.Infof("%s (%s)", check.ID, status)
+	log.Infof(" entry: %s", check.Entry)
+	if check.ResolveErr != nil {
+		log.Warnf(" resolve error: %v", check.ResolveErr)
+	}
+	if len(check.Dials) == 0 {
+		return
+	}
+
+	maxAddrLength := len("address")
+	for _, dial := range check.Dials {
+		maxAddrLength = max(maxAddrLength, len(dial.Addr.String()))
+	}
+	log.Infof(" %-*s | %-20s | %-8s | %-8s | %-5s | agent / error", maxAddrLength, "address", "transport", "dial", "identify", "kad")
+	log.Info(" " + strings.Repeat("-", maxAddrLength+72))
+	for _, dial := range check.Dials {
+		detail := dial.AgentVersion
+		if dial.Err != nil {
+			detail = fmt.Sprintf("%s (%v)", dht.ClassifyError(dial.Err), dial.Err)
+		}
+		log.Infof(" %-*s | %-20s | %-8s | %-8s | %-5t | %s",
+			maxAddrLength, dial.Addr, dial.Transport,
+			dial.DialLatency.Round(1e6), dial.IdentifyLatency.Round(1e6),
+			dial.SupportsKad, detail)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+// bootstrapFlag is the --bootstrap flag shared by the commands that join a network
+func bootstrapFlag(destination *[]string) cli.Flag {
+	return &cli.StringSliceFlag{
+		Name: "bootstrap",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_BOOTSTRAP")},
+		},
+		Usage:       "/p2p/ multiaddrs of the bootstrap peers, replacing the ones of the network (repeatable or comma separated)",
+		Value:       *destination,
+		Destination: destination,
+	}
+}
+
+// seedFileFlag is the --seed-file flag shared by the commands that join a network
+func seedFileFlag(destination *string) cli.Flag {
+	return &cli.StringFlag{
+		Name: "seed-file",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_SEED_FILE")},
+		},
+		Usage:       "extra peers to start from: a previous crawl export (.json, .ndjson, .csv) or a text file with one multiaddr per line",
+		Value:       *destination,
+		Destination: destination,
+	}
+}
+
+// bootstrapPeers resolves the peers to join the network with: the --bootstrap
+// peers or the network's bootstrappers, extended with the ones in the seed file.
+// It fails if that leaves no peer to start from
+func bootstrapPeers(netConf *dht.NetworkConfig, bootstrap []string, seedFile string) ([]peer.AddrInfo, error) {
+	var (
+		bootstrappers []peer.AddrInfo
+		err           error
+	)
+	if len(bootstrap) > 0 {
+		bootstrappers, err = dht.ParseBootstrapAddrs(bootstrap)
+	} else {
+		bootstrappers, err = netConf.BootstrapPeers()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []peer.AddrInfo
+	if seedFile != "" {
+		seeds, err = dht.LoadSeedFile(seedFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	peers := dht.MergeAddrInfos(bootstrappers, seeds)
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("network %s has no bootstrappers: give them with --bootstrap or --seed-file, or add them to the network in --networks.config", netConf.Name)
+	}
+
+	log.WithFields(log.Fields{
+		"bootstrappers": len(bootstrappers),
+		"seeds":         len(seeds),
+	}).Info("starting peers loaded")
+	return peers, nil
+}
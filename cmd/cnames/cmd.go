@@ -17,6 +17,8 @@ import (
 
 const (
 	flagCategoryLogging = "Logging Configuration:"
+	flagCategoryCrawler = "Crawler Configuration:"
+	flagCategoryOutput  = "Output Configuration:"
 )
 
 var rootConfig = &dht.RootConfig{
@@ -24,6 +26,9 @@ var rootConfig = &dht.RootConfig{
 	LogFormat: dht.DefaultLogFormat,
 }
 
+// networkRegistry holds the built-in networks, extended with the ones in --networks.config
+var networkRegistry = dht.DefaultRegistry()
+
 var app = &cli.Command{
 	Name:                  "cnames",
 	Usage:                 "A Celestia's DHT namespace scrapper",
@@ -33,7 +38,12 @@ var app = &cli.Command{
 	Commands: []*cli.Command{
 		cmdLookup,
 		cmdCrawl,
+		cmdProvide,
 		cmdDHTKeys,
+		cmdKeygen,
+		cmdNetworks,
+		cmdBootCheck,
+		cmdDevnet,
 	},
 	After: rootAfter,
 }
@@ -59,22 +69,32 @@ var rootFlags = []cli.Flag{
 		Value:       rootConfig.LogFormat,
 		Category:    flagCategoryLogging,
 	},
+	&cli.StringFlag{
+		Name: "networks.config",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NETWORKS_CONFIG")},
+		},
+		Usage:       "YAML, TOML or JSON file defining extra networks (or overriding the built-in ones)",
+		Destination: &rootConfig.NetworksConfig,
+		Value:       rootConfig.NetworksConfig,
+	},
 }
 
 func main() {
 	sigs := make(chan os.Signal, 1)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
 
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	go func() {
-		defer cancel()
 		defer signal.Stop(sigs)
 
 		select {
 		case <-ctx.Done():
 		case sig := <-sigs:
 			log.WithField("signal", sig.String()).Info("Received termination signal - Stopping...")
+			// tells the crawls apart from the ones stopped on purpose
+			cancel(dht.ErrCrawlInterrupted)
 		}
 	}()
 
@@ -95,6 +115,13 @@ func rootBefore(ctx context.Context, cmd *cli.Command) (context.Context, error)
 		return ctx, err
 	}
 
+	// extend the network registry with the user-defined networks
+	if rootConfig.NetworksConfig != "" {
+		if err := networkRegistry.Load(rootConfig.NetworksConfig); err != nil {
+			return ctx, err
+		}
+	}
+
 	return ctx, nil
 }
 
@@ -2,24 +2,38 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/libp2p/go-libp2p"
-	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
-	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/libp2p/go-libp2p/core/protocol"
-
 	log "github.com/sirupsen/logrus"
 	cli "github.com/urfave/cli/v3"
 
 	"github.com/probe-lab/celestia-dht-scripts/dht"
 )
 
-var crawlConfig = dht.LookupCmdConfig{
+var crawlConfig = dht.CrawlCmdConfig{
 	Network:           dht.DefaultNetwork.String(),
+	Identity:          dht.DefaultIdentity,
+	Mode:              dht.DefaultCrawlMode.String(),
 	IsCustomNamespace: dht.DefaultIsNamespace,
-	Namespace:         dht.DefaultNamespace.String(),
+	Parallelism:       int64(dht.DefaultCrawlParallelism),
+	ConnectTimeout:    dht.DefaultCrawlConnectTimeout,
+	MsgTimeout:        dht.DefaultCrawlMsgTimeout,
+	ProvTimeout:       dht.DefaultCrawlProvTimeout,
+	MaxPeers:          int64(dht.DefaultCrawlMaxPeers),
+	TimeBudget:        dht.DefaultCrawlTimeBudget,
+	Retries:           int64(dht.DefaultCrawlRetries),
+	MinReplication:    int64(dht.DefaultCrawlMinReplication),
+	Progress:          dht.DefaultCrawlProgress,
+	Output:            dht.DefaultCrawlOutput.String(),
+	OutPath:           dht.DefaultCrawlOutPath,
+	GraphFormat:       dht.DefaultCrawlGraphFormat.String(),
+	GraphOutPath:      dht.DefaultCrawlGraphOutPath,
 }
 
 var cmdCrawl = &cli.Command{
@@ -39,100 +53,412 @@ var cmdCrawlFlags = []cli.Flag{
 		Value:       crawlConfig.Network,
 		Destination: &crawlConfig.Network,
 	},
+	identityFlag(&crawlConfig.Identity),
+	bootstrapFlag(&crawlConfig.Bootstrap),
+	seedFileFlag(&crawlConfig.SeedFile),
 	&cli.BoolFlag{
 		Name: "is-custom",
 		Sources: cli.ValueSourceChain{
 			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_IS_CUSTOM")},
 		},
-		Usage:       "is the namespace custom?",
+		Usage:       "take the namespaces as free-form DHT keys instead of known namespaces or aliases",
 		Value:       crawlConfig.IsCustomNamespace,
 		Destination: &crawlConfig.IsCustomNamespace,
 	},
-	&cli.StringFlag{
+	&cli.StringSliceFlag{
 		Name: "namespace",
 		Sources: cli.ValueSourceChain{
-			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NAMESPACE")},
+			// CNAMES_NAMESPACE is kept for the deployments that set it before crawl took several namespaces
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NAMESPACE"), cli.EnvVar("CNAMES_NAMESPACES")},
+		},
+		Usage:       "namespaces or aliases (full, archival, legacy-full, legacy-archival, all) that will be searched, the ones of the network by default (repeatable or comma separated)",
+		Destination: &crawlConfig.Namespaces,
+	},
+	&cli.StringFlag{
+		Name: "mode",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_MODE")},
+		},
+		Usage:       "full crawls the whole network, neighborhood only the peers closest to each namespace",
+		Value:       crawlConfig.Mode,
+		Destination: &crawlConfig.Mode,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.IntFlag{
+		Name: "parallelism",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_PARALLELISM")},
+		},
+		Usage:       "number of peers that are crawled in parallel",
+		Value:       crawlConfig.Parallelism,
+		Destination: &crawlConfig.Parallelism,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.DurationFlag{
+		Name: "connect-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_CONNECT_TIMEOUT")},
+		},
+		Usage:       "time to establish a connection with a remote peer before giving up",
+		Value:       crawlConfig.ConnectTimeout,
+		Destination: &crawlConfig.ConnectTimeout,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.DurationFlag{
+		Name: "msg-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_MSG_TIMEOUT")},
+		},
+		Usage:       "time a single FIND_NODE message is allowed to take before it's deemed failed",
+		Value:       crawlConfig.MsgTimeout,
+		Destination: &crawlConfig.MsgTimeout,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.DurationFlag{
+		Name: "prov-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_PROV_TIMEOUT")},
 		},
-		Usage:       "DHT key or namespace the will be searched",
-		Value:       crawlConfig.Namespace,
-		Destination: &crawlConfig.Namespace,
+		Usage:       "time a single provider query is allowed to take before it's deemed failed",
+		Value:       crawlConfig.ProvTimeout,
+		Destination: &crawlConfig.ProvTimeout,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.IntFlag{
+		Name: "max-peers",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_MAX_PEERS")},
+		},
+		Usage:       "stop the crawl after visiting this number of peers (0 means no limit)",
+		Value:       crawlConfig.MaxPeers,
+		Destination: &crawlConfig.MaxPeers,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.DurationFlag{
+		Name: "time-budget",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_TIME_BUDGET")},
+		},
+		Usage:       "overall duration the crawl is allowed to take (0 means no limit)",
+		Value:       crawlConfig.TimeBudget,
+		Destination: &crawlConfig.TimeBudget,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.IntFlag{
+		Name: "retries",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_RETRIES")},
+		},
+		Usage:       "extra attempts for each failed provider query of a crawled peer (the FIND_NODE requests aren't retried)",
+		Value:       crawlConfig.Retries,
+		Destination: &crawlConfig.Retries,
+		Category:    flagCategoryCrawler,
+	},
+	&cli.IntFlag{
+		Name: "min-replication",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_MIN_REPLICATION")},
+		},
+		Usage:       "flag the providers whose record is returned by fewer crawled peers than this",
+		Value:       crawlConfig.MinReplication,
+		Destination: &crawlConfig.MinReplication,
+		Category:    flagCategoryOutput,
+	},
+	&cli.DurationFlag{
+		Name: "progress",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_CRAWL_PROGRESS")},
+		},
+		Usage:       "interval between crawl progress reports, redrawn every second on a terminal (0 disables them)",
+		Value:       crawlConfig.Progress,
+		Destination: &crawlConfig.Progress,
+		Category:    flagCategoryOutput,
+	},
+	&cli.StringFlag{
+		Name: "output",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_OUTPUT")},
+		},
+		Usage:       "format of the crawl results: text, json, ndjson, csv",
+		Value:       crawlConfig.Output,
+		Destination: &crawlConfig.Output,
+		Category:    flagCategoryOutput,
+	},
+	&cli.StringFlag{
+		Name: "out",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_OUT")},
+		},
+		Usage:       "path of the file where the crawl results are written (\"-\" for stdout)",
+		Value:       crawlConfig.OutPath,
+		Destination: &crawlConfig.OutPath,
+		Category:    flagCategoryOutput,
+	},
+	&cli.StringFlag{
+		Name: "graph-format",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_GRAPH_FORMAT")},
+		},
+		Usage:       "format of the routing table graph: dot, graphml",
+		Value:       crawlConfig.GraphFormat,
+		Destination: &crawlConfig.GraphFormat,
+		Category:    flagCategoryOutput,
+	},
+	&cli.StringFlag{
+		Name: "graph-out",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_GRAPH_OUT")},
+		},
+		Usage:       "path of the file where the routing table graph is written (\"-\" for stdout, empty to skip it)",
+		Value:       crawlConfig.GraphOutPath,
+		Destination: &crawlConfig.GraphOutPath,
+		Category:    flagCategoryOutput,
 	},
 }
 
 func cmdCrawlAction(ctx context.Context, cmd *cli.Command) error {
+	netConf, err := networkRegistry.Get(crawlConfig.Network)
+	if err != nil {
+		return err
+	}
+	// default to the namespaces known for the network, which may be aliases too
+	if !cmd.IsSet("namespace") {
+		crawlConfig.Namespaces, err = netConf.ResolveNamespaces(netConf.Namespaces, false)
+	} else {
+		crawlConfig.Namespaces, err = netConf.ResolveNamespaces(crawlConfig.Namespaces, crawlConfig.IsCustomNamespace)
+	}
+	if err != nil {
+		return err
+	}
+
 	log.WithFields(log.Fields{
-		"network":      crawlConfig.Network,
-		"is-custom-ns": crawlConfig.IsCustomNamespace,
-		"namespace":    crawlConfig.Namespace,
+		"network":         crawlConfig.Network,
+		"mode":            crawlConfig.Mode,
+		"is-custom-ns":    crawlConfig.IsCustomNamespace,
+		"namespaces":      crawlConfig.Namespaces,
+		"parallelism":     crawlConfig.Parallelism,
+		"connect-timeout": crawlConfig.ConnectTimeout,
+		"msg-timeout":     crawlConfig.MsgTimeout,
+		"prov-timeout":    crawlConfig.ProvTimeout,
+		"max-peers":       crawlConfig.MaxPeers,
+		"time-budget":     crawlConfig.TimeBudget,
+		"retries":         crawlConfig.Retries,
+		"min-replication": crawlConfig.MinReplication,
+		"progress":        crawlConfig.Progress,
+		"output":          crawlConfig.Output,
+		"out":             crawlConfig.OutPath,
+		"graph-format":    crawlConfig.GraphFormat,
+		"graph-out":       crawlConfig.GraphOutPath,
 	}).Info("starting cnames-crawl...")
 
-	network := dht.NetworkFromString(crawlConfig.Network)
-	kadProtocol := network.KadProtocol()
+	mode, err := dht.CrawlModeFromString(crawlConfig.Mode)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := dht.OutputFormatFromString(crawlConfig.Output)
+	if err != nil {
+		return err
+	}
+	graphFormat, err := dht.GraphFormatFromString(crawlConfig.GraphFormat)
+	if err != nil {
+		return err
+	}
+
+	network := netConf.Network()
 
 	// get bootstrappers
-	bootstrapers := dht.BootstrapPeers(network)
-	startingPeers := make([]*peer.AddrInfo, len(bootstrapers))
-	for idx, bootstrapper := range bootstrapers {
-		startingPeers[idx] = &bootstrapper
+	bootstrapers, err := bootstrapPeers(netConf, crawlConfig.Bootstrap, crawlConfig.SeedFile)
+	if err != nil {
+		return err
 	}
 
 	// libp2p host
-	h, err := libp2p.New(
-		libp2p.UserAgent(dht.CustomUserAgent),
-		libp2p.Identity(dht.LoadPrivKey()),
-		// libp2p.NATPortMap(), // enable upnp
-		libp2p.DisableRelay(),
-	)
+	privKey, err := loadIdentity(crawlConfig.Identity)
 	if err != nil {
 		return err
 	}
+
+	client, err := dht.NewClient(netConf, dht.WithIdentity(privKey), dht.WithBootstrappers(bootstrapers))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
 	log.Info("HOST info:")
-	log.Info("- Peer ID:      ", h.ID())
+	log.Info("- Peer ID:      ", client.ID())
 	log.Info("- Network:      ", network)
-	log.Info("- Protocol:     ", kadProtocol)
-	log.Info("- Protocols:    ", h.Mux().Protocols())
+	log.Info("- Protocol:     ", netConf.KadProtocol())
+	log.Info("- Protocols:    ", client.Host().Mux().Protocols())
 	log.Info("- Agent Version:", dht.CustomUserAgent)
 
-	// protocol messenger for the DHT queries
-	prots := []protocol.ID{kadProtocol}
-	pm, err := pb.NewProtocolMessenger(&dht.MessageSender{H: h, Protocols: []protocol.ID{kadProtocol}, Timeout: 30 * time.Second})
-	if err != nil {
-		panic(err)
+	// keep track of the running crawl for the progress reports and the SIGUSR1 dumps
+	var (
+		live     atomic.Pointer[dht.CrawlResults]
+		tracker  *dht.ProgressTracker
+		progress *progressReporter
+	)
+	if crawlConfig.Progress > 0 {
+		tracker = dht.NewProgressTracker()
+		progress = startProgress(tracker, crawlConfig.Progress)
 	}
+	observer := dht.CrawlObserverFunc(func(e dht.CrawlEvent) {
+		if started, ok := e.(dht.CrawlStarted); ok {
+			live.Store(started.Results)
+		}
+		if tracker != nil {
+			tracker.OnCrawlEvent(e)
+		}
+	})
+	stopDumps := dumpOnSignal(&live, network, outputFormat, crawlConfig.OutPath)
 
-	// for the crawler
-	dhtCrawler, err := dht.New(h, prots, pm)
+	report, err := client.Crawl(ctx, dht.CrawlOptions{
+		Namespaces:     crawlConfig.Namespaces,
+		Mode:           mode,
+		Parallelism:    int(crawlConfig.Parallelism),
+		ConnectTimeout: crawlConfig.ConnectTimeout,
+		MsgTimeout:     crawlConfig.MsgTimeout,
+		ProvTimeout:    crawlConfig.ProvTimeout,
+		MaxPeers:       int(crawlConfig.MaxPeers),
+		TimeBudget:     crawlConfig.TimeBudget,
+		Retries:        int(crawlConfig.Retries),
+		Observer:       observer,
+	})
+	stopDumps()
+	if progress != nil {
+		progress.Stop()
+	}
 	if err != nil {
-		panic(err)
+		return err
+	}
+	for _, trace := range report.Neighborhoods {
+		printNeighborhoodTrace(trace)
+	}
+	results := report.Results
+	if results.GetStatus() == dht.CrawlStatusInterrupted {
+		log.Warn("crawl interrupted, reporting and exporting the partial results")
 	}
-
-	results := dhtCrawler.Run(ctx, startingPeers, crawlConfig.Namespace)
 
 	succPeers := results.GetSuccPeers()
 	failedPeers := results.GetFailedPeers()
-	providers := results.GetProvPeers()
 	agentVersions := results.GetAgentDistributions()
 
-	log.Infof("Found %s nodes:\n", crawlConfig.Namespace)
-	for idx, p := range providers {
-		log.Infof("%s -> peer_id: %s", idx, p.ID.String())
+	for _, ns := range results.GetRecordKeys() {
+		log.Infof("Found %s nodes:\n", ns)
+		replication := results.GetReplicationFactors(ns)
+		for idx, p := range results.GetProvPeers(ns) {
+			log.Infof("%s -> peer_id: %s | replication: %d", idx, p.ID.String(), replication[idx])
+		}
+		for idx, factor := range replication {
+			if factor < int(crawlConfig.MinReplication) {
+				log.Warnf("%s provider %s is only held by %d peers (min: %d)", ns, idx, factor, crawlConfig.MinReplication)
+			}
+		}
 	}
 
 	log.Infof("Summary of the crawl on %s:", network)
+	log.Infof(" - Status: %s", results.GetStatus())
 	log.Infof(" - Duration: %s", results.GetCrawlerDuration())
 	log.Infof(" - Total discovered nodes: %d", len(succPeers)+len(failedPeers))
 	log.Infof(" - Successful connected nodes: %d", len(succPeers))
 	log.Infof(" - Failed to connect nodes: %d", len(failedPeers))
-	log.Infof(" - Advertised %s nodes: %d", crawlConfig.Namespace, len(providers))
+	logQueryFailures(results)
+	log.Infof(" - Advertised nodes per namespace:")
+	printProvidersTable(results.GetRecordKeys(), results.GetProvCounts())
+	for _, ns := range results.GetRecordKeys() {
+		report, err := results.PlacementReport(ns, dht.KademliaK)
+		if err != nil {
+			return err
+		}
+		printPlacementReport(report)
+	}
 	log.Infof(" - AgentVersion distribution:")
-	printTable(agentVersions)
+	printTable("agent_version", agentVersions)
+	log.Infof(" - Failure distribution:")
+	printTable("failure_category", results.GetFailureDistributions())
 
-	return nil
+	graph := results.Graph()
+	log.Infof(" - Routing table graph: %d nodes, %d edges", len(graph.Nodes), len(graph.Edges))
+	if crawlConfig.GraphOutPath != "" {
+		if err := writeGraph(graph, graphFormat, crawlConfig.GraphOutPath); err != nil {
+			return err
+		}
+	}
+
+	if outputFormat == dht.OutputText {
+		return nil
+	}
+	return writeSnapshot(results.Snapshot(network), outputFormat, crawlConfig.OutPath)
 }
 
-func printTable(data map[string]int) {
+// dumpOnSignal exports a snapshot of the running crawl to its own file (see dumpPath) each time
+// one of the dumpSignals is received, without stopping it. The returned function stops listening for the signals
+func dumpOnSignal(live *atomic.Pointer[dht.CrawlResults], network dht.Network, format dht.OutputFormat, path string) func() {
+	if len(dumpSignals) == 0 {
+		return func() {}
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, dumpSignals...)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case sig := <-sigs:
+				log.WithField("signal", sig.String()).Info("Received dump signal - exporting the in-progress results...")
+				dumpSnapshot(live.Load(), network, format, path)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+		wg.Wait()
+	}
+}
+
+// dumpSnapshot logs the counters of the in-progress results and exports them, unless the output is text
+func dumpSnapshot(results *dht.CrawlResults, network dht.Network, format dht.OutputFormat, path string) {
+	if results == nil {
+		log.Warn("the crawl didn't start yet, there is nothing to export")
+		return
+	}
+	snapshot := results.Snapshot(network)
+	log.WithFields(log.Fields{
+		"status":     snapshot.Metadata.Status,
+		"duration":   time.Duration(snapshot.Metadata.DurationMs) * time.Millisecond,
+		"peers":      snapshot.Metadata.TotalPeers,
+		"successful": snapshot.Metadata.SuccPeers,
+		"failed":     snapshot.Metadata.FailPeers,
+		"providers":  snapshot.Metadata.Providers,
+	}).Info("in-progress crawl snapshot")
+
+	if format == dht.OutputText {
+		return
+	}
+	if err := writeSnapshot(snapshot, format, dumpPath(path, format, time.Now())); err != nil {
+		log.Errorf("exporting the in-progress results: %s", err)
+	}
+}
+
+// dumpPath returns the file of a dump taken at the given time: <out>.partial-<unix-ts>, so that
+// it neither overwrites the previous dumps nor gets overwritten by the final results. The dumps
+// of a crawl written to stdout go to crawl.<format>.partial-<unix-ts>, as they would corrupt its output
+func dumpPath(path string, format dht.OutputFormat, at time.Time) string {
+	if path == "-" || path == "" {
+		path = "crawl." + format.String()
+	}
+	return fmt.Sprintf("%s.partial-%d", path, at.Unix())
+}
+
+func printTable(header string, data map[string]int) {
 	// Determine the maximum key length for formatting
-	var maxKeyLength int
+	maxKeyLength := len(header)
 	for key := range data {
 		if len(key) > maxKeyLength {
 			maxKeyLength = len(key)
@@ -140,7 +466,7 @@ func printTable(data map[string]int) {
 	}
 
 	// Print headerd
-	log.Infof("%-*s | nodes\n", maxKeyLength, "agent_version")
+	log.Infof("%-*s | nodes\n", maxKeyLength, header)
 	log.Info(strings.Repeat("-", maxKeyLength+8))
 
 	// Print key-value pairs
@@ -153,3 +479,81 @@ func printTable(data map[string]int) {
 	log.Info(strings.Repeat("-", maxKeyLength+8))
 	log.Infof("%-*s | %v\n", maxKeyLength, "total", data["total"])
 }
+
+func logQueryFailures(results *dht.CrawlResults) {
+	var failed, recovered int
+	var latency time.Duration
+	for _, queries := range results.GetQueryFailures() {
+		for _, query := range queries {
+			if query.Recovered {
+				recovered++
+			} else {
+				failed++
+			}
+			latency += query.Latency
+		}
+	}
+
+	log.Infof(" - Connected but provider query failed nodes: %d", len(results.GetQueryFailedPeers()))
+	log.Infof(" - Failed provider queries: %d (recovered after retries: %d)", failed, recovered)
+	if failed+recovered > 0 {
+		log.Infof(" - Avg latency of the failing provider queries: %s", latency/time.Duration(failed+recovered))
+	}
+}
+
+func printPlacementReport(report *dht.PlacementReport) {
+	log.Infof(" - Placement of %s: %d/%d closest peers hold the record, %d far peers hold it", report.Namespace, report.Holders(), len(report.Closest), len(report.FarHolders))
+
+	log.Infof("   %d closest peers to %s:", len(report.Closest), report.Namespace)
+	printClosestPeers(report.Closest)
+	if len(report.FarHolders) > 0 {
+		log.Infof("   peers outside of the closest set holding %s:", report.Namespace)
+		printClosestPeers(report.FarHolders)
+	}
+}
+
+func printClosestPeers(peers []dht.ClosestPeer) {
+	log.Infof("%-4s | %-52s | %-3s | %-12s | holds_record", "rank", "peer_id", "cpl", "status")
+	log.Info(strings.Repeat("-", 95))
+	for i, p := range peers {
+		log.Infof("%-4d | %-52s | %-3d | %-12s | %t", i+1, p.ID.String(), p.CPL, p.Status, p.HoldsRecord)
+	}
+	log.Info(strings.Repeat("-", 95))
+}
+
+func printNeighborhoodTrace(trace *dht.NeighborhoodTrace) {
+	log.Infof("Neighborhood of %s (converged: %t):", trace.Namespace, trace.Converged)
+	log.Infof("%-5s | %-7s | %-6s | %-10s | %-7s | max_cpl", "round", "queried", "failed", "discovered", "changed")
+	log.Info(strings.Repeat("-", 60))
+	for _, round := range trace.Rounds {
+		maxCPL := 0
+		if len(round.Closest) > 0 {
+			maxCPL = round.Closest[0].CPL
+		}
+		log.Infof("%-5d | %-7d | %-6d | %-10d | %-7t | %d", round.Round, round.Queried, round.Failed, round.Discovered, round.Changed, maxCPL)
+	}
+	log.Info(strings.Repeat("-", 60))
+
+	log.Infof("   final %d closest peers to %s:", len(trace.Closest), trace.Namespace)
+	printClosestPeers(trace.Closest)
+}
+
+func printProvidersTable(namespaces []string, counts map[string]int) {
+	// Determine the maximum namespace length for formatting
+	maxKeyLength := len("namespace")
+	for _, ns := range namespaces {
+		if len(ns) > maxKeyLength {
+			maxKeyLength = len(ns)
+		}
+	}
+
+	// Print header
+	log.Infof("%-*s | providers\n", maxKeyLength, "namespace")
+	log.Info(strings.Repeat("-", maxKeyLength+12))
+
+	// Print the namespaces in the requested order
+	for _, ns := range namespaces {
+		log.Infof("%-*s | %v\n", maxKeyLength, ns, counts[ns])
+	}
+	log.Info(strings.Repeat("-", maxKeyLength+12))
+}
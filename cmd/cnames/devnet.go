@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/probe-lab/celestia-dht-scripts/devnet"
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+var devnetConfig = dht.DevnetCmdConfig{
+	Nodes:             int64(dht.DefaultDevnetNodes),
+	Listen:            dht.DefaultDevnetListen,
+	Providers:         dht.DefaultDevnetProviders,
+	IsCustomNamespace: dht.DefaultIsNamespace,
+	OutPath:           dht.DefaultDevnetOutPath,
+}
+
+var cmdDevnet = &cli.Command{
+	Name:   "devnet",
+	Usage:  "runs a local DHT network on the loopback interface for the private network until interrupted",
+	Flags:  cmdDevnetFlags,
+	Action: cmdDevnetAction,
+}
+
+var cmdDevnetFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name: "nodes",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_DEVNET_NODES")},
+		},
+		Usage:       "number of DHT nodes in the devnet",
+		Value:       devnetConfig.Nodes,
+		Destination: &devnetConfig.Nodes,
+	},
+	&cli.StringFlag{
+		Name: "listen",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_DEVNET_LISTEN")},
+		},
+		Usage:       "multiaddr every node listens on, with port 0 so each gets its own",
+		Value:       devnetConfig.Listen,
+		Destination: &devnetConfig.Listen,
+	},
+	&cli.StringSliceFlag{
+		Name: "provide",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_DEVNET_PROVIDE")},
+		},
+		Usage:       "namespace=count pairs with the number of nodes that advertise each namespace (repeatable or comma separated)",
+		Value:       devnetConfig.Providers,
+		Destination: &devnetConfig.Providers,
+	},
+	&cli.BoolFlag{
+		Name: "is-custom",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_IS_CUSTOM")},
+		},
+		Usage:       "take the namespaces as free-form DHT keys instead of known namespaces or aliases",
+		Value:       devnetConfig.IsCustomNamespace,
+		Destination: &devnetConfig.IsCustomNamespace,
+	},
+	&cli.StringFlag{
+		Name: "out",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_DEVNET_OUT")},
+		},
+		Usage:       "write the multiaddrs of the nodes to this path, to be used as --seed-file (- means stdout)",
+		Value:       devnetConfig.OutPath,
+		Destination: &devnetConfig.OutPath,
+	},
+}
+
+func cmdDevnetAction(ctx context.Context, cmd *cli.Command) error {
+	log.WithFields(log.Fields{
+		"nodes":     devnetConfig.Nodes,
+		"listen":    devnetConfig.Listen,
+		"providers": devnetConfig.Providers,
+		"out":       devnetConfig.OutPath,
+	}).Info("starting cnames-devnet...")
+
+	netConf, err := networkRegistry.Get(devnet.Network.String())
+	if err != nil {
+		return err
+	}
+	opts := []devnet.Option{
+		devnet.WithNodes(int(devnetConfig.Nodes)),
+		devnet.WithListenAddr(devnetConfig.Listen),
+	}
+	for _, provider := range devnetConfig.Providers {
+		namespace, count, err := parseDevnetProvider(netConf, provider)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, devnet.WithProviders(namespace, count))
+	}
+
+	net, err := devnet.New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := net.Close(); err != nil {
+			log.WithError(err).Warn("closing the devnet")
+		}
+	}()
+
+	addrs, err := net.BootstrapAddrs()
+	if err != nil {
+		return err
+	}
+	log.Infof("devnet running with %d nodes on the %s network (%s):", len(net.Nodes), devnet.Network, netConf.KadProtocol())
+	for i, node := range net.Nodes {
+		log.WithFields(log.Fields{
+			"routing-table": node.DHT.RoutingTable().Size(),
+			"provides":      strings.Join(node.Namespaces, ","),
+		}).Infof(" %2d: %s", i, node.Host.ID())
+	}
+	if devnetConfig.OutPath != "" {
+		err := writeToPath(devnetConfig.OutPath, func(w io.Writer) error {
+			_, err := io.WriteString(w, strings.Join(addrs, "\n")+"\n")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("writing the devnet peers: %w", err)
+		}
+		log.Infof("point the other commands at it with: --network %s --seed-file %s", devnet.Network, devnetConfig.OutPath)
+	} else {
+		log.Infof("point the other commands at it with: --network %s --bootstrap %s", devnet.Network, addrs[0])
+	}
+
+	<-ctx.Done()
+	log.Info("stopping the devnet")
+	return nil
+}
+
+// parseDevnetProvider parses a namespace=count pair, resolving the namespace like the other commands
+func parseDevnetProvider(netConf *dht.NetworkConfig, provider string) (string, int, error) {
+	idx := strings.LastIndex(provider, "=")
+	if idx < 0 {
+		return "", 0, fmt.Errorf("invalid provider %q: expected namespace=count", provider)
+	}
+	count, err := strconv.Atoi(provider[idx+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid provider count in %q: %w", provider, err)
+	}
+	namespaces, err := netConf.ResolveNamespaces([]string{provider[:idx]}, devnetConfig.IsCustomNamespace)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(namespaces) > 1 {
+		return "", 0, fmt.Errorf("invalid provider %q: %q resolves to %d namespaces", provider, provider[:idx], len(namespaces))
+	}
+	return namespaces[0], count, nil
+}
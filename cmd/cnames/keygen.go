@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+var keygenConfig = dht.KeygenCmdConfig{}
+
+var cmdKeygen = &cli.Command{
+	Name:  "keygen",
+	Usage: "generates a new libp2p identity that can be used with --identity",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "out",
+			Required:    true,
+			Usage:       "path of the file where the private key is written",
+			Value:       keygenConfig.Out,
+			Destination: &keygenConfig.Out,
+		},
+		&cli.BoolFlag{
+			Name:        "force",
+			Usage:       "overwrite the file if it already exists",
+			Value:       keygenConfig.Force,
+			Destination: &keygenConfig.Force,
+		},
+	},
+	Action: cmdKeygenAction,
+}
+
+func cmdKeygenAction(ctx context.Context, cmd *cli.Command) error {
+	peerID, err := dht.GenerateIdentity(keygenConfig.Out, keygenConfig.Force)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"peer-id": peerID,
+		"out":     keygenConfig.Out,
+	}).Info("new identity generated")
+	return nil
+}
+
+// identityFlag is the --identity flag shared by the commands that spawn a libp2p host
+func identityFlag(destination *string) cli.Flag {
+	return &cli.StringFlag{
+		Name: "identity",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_IDENTITY")},
+		},
+		Usage:       "libp2p identity of the host: path to a key file (see keygen), \"ephemeral\" or \"legacy\" (the key embedded in cnames)",
+		Value:       *destination,
+		Destination: destination,
+	}
+}
+
+// loadIdentity loads the private key of the given identity and reports the peer ID that will be used
+func loadIdentity(identity string) (crypto.PrivKey, error) {
+	privKey, err := dht.LoadIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	peerID, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"identity": identity,
+		"peer-id":  peerID,
+	}).Info("using libp2p identity")
+	return privKey, nil
+}
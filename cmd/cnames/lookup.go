@@ -2,13 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"time"
 
-	kad "github.com/libp2p/go-libp2p-kad-dht"
-	routingdisc "github.com/libp2p/go-libp2p/p2p/discovery/routing"
-
-	"github.com/libp2p/go-libp2p"
-	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/probe-lab/celestia-dht-scripts/dht"
 
 	log "github.com/sirupsen/logrus"
@@ -17,8 +15,17 @@ import (
 
 var lookupConfig = dht.LookupCmdConfig{
 	Network:           dht.DefaultNetwork.String(),
+	Identity:          dht.DefaultIdentity,
 	IsCustomNamespace: dht.DefaultIsNamespace,
 	Namespace:         dht.DefaultNamespace.String(),
+	MinRoutingTable:   int64(dht.DefaultLookupMinRoutingTable),
+	BootTimeout:       dht.DefaultLookupBootTimeout,
+	FindTimeout:       dht.DefaultLookupFindTimeout,
+	Repeat:            int64(dht.DefaultLookupRepeat),
+	Interval:          dht.DefaultLookupInterval,
+	Fresh:             dht.DefaultLookupFresh.String(),
+	TraceFormat:       dht.DefaultLookupTraceFormat.String(),
+	TraceOutPath:      dht.DefaultLookupTraceOutPath,
 }
 
 var cmdLookup = &cli.Command{
@@ -38,12 +45,15 @@ var cmdLookupFlags = []cli.Flag{
 		Value:       lookupConfig.Network,
 		Destination: &lookupConfig.Network,
 	},
+	identityFlag(&lookupConfig.Identity),
+	bootstrapFlag(&lookupConfig.Bootstrap),
+	seedFileFlag(&lookupConfig.SeedFile),
 	&cli.BoolFlag{
 		Name: "is-custom",
 		Sources: cli.ValueSourceChain{
 			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_IS_CUSTOM")},
 		},
-		Usage:       "is the namespace custom?",
+		Usage:       "take the namespace as a free-form DHT key instead of a known namespace or alias",
 		Value:       lookupConfig.IsCustomNamespace,
 		Destination: &lookupConfig.IsCustomNamespace,
 	},
@@ -52,10 +62,94 @@ var cmdLookupFlags = []cli.Flag{
 		Sources: cli.ValueSourceChain{
 			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NAMESPACE")},
 		},
-		Usage:       "DHT key or namespace the will be searched",
+		Usage:       "namespace or alias (full, archival, legacy-full, legacy-archival) that will be searched",
 		Value:       lookupConfig.Namespace,
 		Destination: &lookupConfig.Namespace,
 	},
+	&cli.IntFlag{
+		Name: "min-routing-table",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_MIN_ROUTING_TABLE")},
+		},
+		Usage:       "peers the routing table needs before the lookup starts",
+		Value:       lookupConfig.MinRoutingTable,
+		Destination: &lookupConfig.MinRoutingTable,
+	},
+	&cli.DurationFlag{
+		Name: "bootstrap-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_BOOTSTRAP_TIMEOUT")},
+		},
+		Usage:       "maximum time to wait for the routing table to fill up before starting the lookup anyway",
+		Value:       lookupConfig.BootTimeout,
+		Destination: &lookupConfig.BootTimeout,
+	},
+	&cli.DurationFlag{
+		Name: "find-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_FIND_TIMEOUT")},
+		},
+		Usage:       "maximum time the provider lookup is allowed to take",
+		Value:       lookupConfig.FindTimeout,
+		Destination: &lookupConfig.FindTimeout,
+	},
+	&cli.IntFlag{
+		Name: "repeat",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_REPEAT")},
+		},
+		Usage:       "number of lookups to run, aggregating their results",
+		Value:       lookupConfig.Repeat,
+		Destination: &lookupConfig.Repeat,
+	},
+	&cli.DurationFlag{
+		Name: "interval",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_INTERVAL")},
+		},
+		Usage:       "time between repeated lookups",
+		Value:       lookupConfig.Interval,
+		Destination: &lookupConfig.Interval,
+	},
+	&cli.StringFlag{
+		Name: "fresh",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_FRESH")},
+		},
+		Usage:       "what to renew on every repeated lookup: none, client (new host and DHT client) or identity (also a new ephemeral peer ID)",
+		Value:       lookupConfig.Fresh,
+		Destination: &lookupConfig.Fresh,
+	},
+	&cli.BoolFlag{
+		Name: "trace",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_TRACE")},
+		},
+		Usage:       "record every peer queried during the lookup, with the closer peers it returned, its latency and errors",
+		Value:       lookupConfig.Trace,
+		Destination: &lookupConfig.Trace,
+		Category:    flagCategoryOutput,
+	},
+	&cli.StringFlag{
+		Name: "trace-format",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_TRACE_FORMAT")},
+		},
+		Usage:       "format of the lookup trace: tree, timeline or json",
+		Value:       lookupConfig.TraceFormat,
+		Destination: &lookupConfig.TraceFormat,
+		Category:    flagCategoryOutput,
+	},
+	&cli.StringFlag{
+		Name: "trace-out",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_LOOKUP_TRACE_OUT")},
+		},
+		Usage:       "path the lookup trace is written to (- means stdout)",
+		Value:       lookupConfig.TraceOutPath,
+		Destination: &lookupConfig.TraceOutPath,
+		Category:    flagCategoryOutput,
+	},
 }
 
 func cmdLookupAction(ctx context.Context, cmd *cli.Command) error {
@@ -63,74 +157,206 @@ func cmdLookupAction(ctx context.Context, cmd *cli.Command) error {
 		"network":      lookupConfig.Network,
 		"is-custom-ns": lookupConfig.IsCustomNamespace,
 		"namespace":    lookupConfig.Namespace,
+		"min-rt-size":  lookupConfig.MinRoutingTable,
+		"boot-timeout": lookupConfig.BootTimeout,
+		"find-timeout": lookupConfig.FindTimeout,
+		"repeat":       lookupConfig.Repeat,
+		"interval":     lookupConfig.Interval,
+		"fresh":        lookupConfig.Fresh,
+		"trace":        lookupConfig.Trace,
 	}).Info("starting cnames-lookup...")
 
-	network := dht.NetworkFromString(lookupConfig.Network)
-	kadProtocol := network.KadPrefix()
-
-	h, err := libp2p.New(
-		libp2p.UserAgent(dht.CustomUserAgent),
-		libp2p.Identity(dht.LoadPrivKey()),
-		// libp2p.NATPortMap(), // enable upnp
-		libp2p.DisableRelay(),
-	)
+	if lookupConfig.BootTimeout <= 0 || lookupConfig.FindTimeout <= 0 {
+		return fmt.Errorf("bootstrap-timeout and find-timeout must be positive")
+	}
+	if lookupConfig.Repeat < 1 {
+		return fmt.Errorf("repeat must be at least 1, got %d", lookupConfig.Repeat)
+	}
+	if lookupConfig.Interval < 0 {
+		return fmt.Errorf("interval must not be negative, got %s", lookupConfig.Interval)
+	}
+	fresh, err := dht.FreshModeFromString(lookupConfig.Fresh)
+	if err != nil {
+		return err
+	}
+	traceFormat, err := dht.TraceFormatFromString(lookupConfig.TraceFormat)
 	if err != nil {
 		return err
 	}
+	if fresh == dht.FreshIdentity && cmd.IsSet("identity") {
+		log.Warnf("--identity %s is ignored, every lookup uses a new ephemeral identity", lookupConfig.Identity)
+	}
 
-	dhtOpts := []kad.Option{
-		kad.Mode(kad.ModeClient),
-		kad.BootstrapPeers(dht.BootstrapPeers(network)...),
-		kad.ProtocolPrefix(kadProtocol),
+	netConf, err := networkRegistry.Get(lookupConfig.Network)
+	if err != nil {
+		return err
+	}
+	namespaces, err := netConf.ResolveNamespaces([]string{lookupConfig.Namespace}, lookupConfig.IsCustomNamespace)
+	if err != nil {
+		return err
+	}
+	if len(namespaces) > 1 {
+		return fmt.Errorf("lookup takes a single namespace, %q resolves to %d", lookupConfig.Namespace, len(namespaces))
 	}
-	dhtCli, err := kad.New(ctx, h, dhtOpts...)
+	namespace := namespaces[0]
+	bootstrappers, err := bootstrapPeers(netConf, lookupConfig.Bootstrap, lookupConfig.SeedFile)
 	if err != nil {
 		return err
 	}
 
-	bootnodes := 0
-	for _, bootstrapper := range dht.BootstrapPeers(network) {
-		if err := h.Connect(ctx, bootstrapper); err != nil {
-			log.Warn("couldn't connect to", bootstrapper, ":", err)
-		} else {
-			bootnodes++
+	var (
+		client  *dht.Client
+		results = make([]*dht.LookupResult, 0, lookupConfig.Repeat)
+		traces  = make([]*dht.LookupTrace, 0)
+	)
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+	for run := 1; run <= int(lookupConfig.Repeat); run++ {
+		if run > 1 {
+			select {
+			case <-time.After(lookupConfig.Interval):
+			case <-ctx.Done():
+				log.Warn("lookup interrupted, aggregating the completed runs")
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		if client == nil || fresh != dht.FreshNone {
+			if client != nil {
+				client.Close()
+				client = nil
+			}
+			identity := lookupConfig.Identity
+			if fresh == dht.FreshIdentity {
+				identity = dht.IdentityEphemeral
+			}
+			client, err = newLookupClient(ctx, identity, netConf, bootstrappers)
+			if err != nil {
+				return err
+			}
+		}
+
+		if lookupConfig.Repeat > 1 {
+			log.Infof("Lookup %d/%d:", run, lookupConfig.Repeat)
+		}
+		result, trace, err := lookup(ctx, client, namespace)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		if trace != nil {
+			traces = append(traces, trace)
 		}
 	}
 
+	if lookupConfig.Repeat > 1 {
+		printLookupStats(dht.AggregateLookups(results))
+	}
+	if lookupConfig.Trace {
+		return writeTraces(traces, traceFormat, lookupConfig.TraceOutPath)
+	}
+	return nil
+}
+
+// newLookupClient creates a client for the network and bootstraps it
+func newLookupClient(ctx context.Context, identity string, netConf *dht.NetworkConfig, bootstrappers []peer.AddrInfo) (*dht.Client, error) {
+	privKey, err := loadIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dht.NewClient(netConf, dht.WithIdentity(privKey), dht.WithBootstrappers(bootstrappers))
+	if err != nil {
+		return nil, err
+	}
+	boot, err := client.Bootstrap(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	for p, err := range boot.Failed {
+		log.Warn("couldn't connect to ", p, ": ", err)
+	}
+
 	log.Info("HOST info:")
-	log.Info("- Peer ID:			", h.ID())
-	log.Info("- Network:			", network)
-	log.Info("- Protocols:			", h.Mux().Protocols())
+	log.Info("- Peer ID:			", client.ID())
+	log.Info("- Network:			", netConf.Network())
+	log.Info("- Protocols:			", client.Host().Mux().Protocols())
 	log.Info("- Agent Version:		", dht.CustomUserAgent)
-	log.Info("- Bootnodes:			", bootnodes)
+	log.Info("- Bootnodes:			", len(boot.Connected))
+	return client, nil
+}
 
-	err = dhtCli.Bootstrap(ctx)
+// lookup searches the providers of the namespace once the routing table of the client is ready,
+// tracing the queries if --trace is set
+func lookup(ctx context.Context, client *dht.Client, namespace string) (*dht.LookupResult, *dht.LookupTrace, error) {
+	n := 1
+	report, err := client.Lookup(ctx, dht.LookupOptions{
+		Namespace:        namespace,
+		MinRoutingTable:  int(lookupConfig.MinRoutingTable),
+		BootstrapTimeout: lookupConfig.BootTimeout,
+		FindTimeout:      lookupConfig.FindTimeout,
+		Trace:            lookupConfig.Trace,
+		OnReady: func(report *dht.LookupReport) {
+			if !report.Ready {
+				log.Warnf("routing table only reached %d of %d peers after %s, looking up anyway", report.RoutingTableSize, lookupConfig.MinRoutingTable, report.BootstrapTime.Round(time.Millisecond))
+			}
+			log.Info("- Routing table size:	", report.RoutingTableSize)
+			log.Info("- Bootstrap time:		", report.BootstrapTime.Round(time.Millisecond))
+			log.Info("Found peers:")
+		},
+		OnProvider: func(p dht.FoundProvider) {
+			log.Infof("%d -> peer_id: %s (after %s)", n, p.ID.String(), p.Elapsed.Round(time.Millisecond))
+			n += 1
+		},
+	})
 	if err != nil {
-		return nil
+		return nil, nil, err
 	}
-	time.Sleep(5 * time.Second)
-
-	log.Info("- Routing table size:	", dhtCli.RoutingTable().Size())
 
-	disc := routingdisc.NewRoutingDiscovery(dhtCli)
+	result := report.Result
+	log.Info("Total peers found:", len(result.Providers))
+	log.Info("- Time to first provider:	", result.TimeToFirstProvider().Round(time.Millisecond))
+	log.Info("- Time to last provider:	", result.TimeToLastProvider().Round(time.Millisecond))
+	log.Info("- Lookup duration:		", result.Duration.Round(time.Millisecond))
+	return result, report.Trace, nil
+}
 
-	findCtx, cancelFunc := context.WithTimeout(ctx, 15*time.Second)
-	defer cancelFunc()
+func printLookupStats(stats *dht.LookupStats) {
+	log.Infof("Summary of %d lookups:", stats.Runs)
+	log.Infof("- Success rate:		%.1f%% (%d/%d)", 100*stats.SuccessRate(), stats.Successful, stats.Runs)
 
-	peers, err := disc.FindPeers(findCtx, lookupConfig.Namespace, discovery.Limit(0))
-	if err != nil {
-		return err
+	counts := make([]int, 0, len(stats.ProviderCounts))
+	for count := range stats.ProviderCounts {
+		counts = append(counts, count)
+	}
+	sort.Ints(counts)
+	log.Info("- Providers found per lookup:")
+	for _, count := range counts {
+		log.Infof("   %4d providers: %d lookups", count, stats.ProviderCounts[count])
 	}
 
-	time.Sleep(5 * time.Second)
+	log.Infof("- Latency             | %-8s | %-8s | %-8s | %-8s | %-8s", "min", "p50", "p90", "p99", "max")
+	printPercentiles("time to first", stats.TimeToFirst)
+	printPercentiles("time to last", stats.TimeToLast)
+	printPercentiles("lookup duration", stats.Duration)
 
-	log.Info("Found peers:")
-	c := 1
-	for p := range peers {
-		log.Infof("%d -> peer_id: %s", c, p.ID.String())
-		c += 1
+	log.Infof("- Providers found in any lookup (union): %d", len(stats.Union))
+	for _, p := range stats.Union {
+		log.Infof("   %s", p)
+	}
+	log.Infof("- Providers found in every lookup (intersection): %d", len(stats.Intersection))
+	for _, p := range stats.Intersection {
+		log.Infof("   %s", p)
 	}
-	log.Info("Total peers found:", c-1)
+}
 
-	return nil
+func printPercentiles(name string, p dht.Percentiles) {
+	ms := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	log.Infof("  %-19s | %-8s | %-8s | %-8s | %-8s | %-8s", name, ms(p.Min), ms(p.P50), ms(p.P90), ms(p.P99), ms(p.Max))
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v3"
+)
+
+var cmdNetworks = &cli.Command{
+	Name:   "networks",
+	Usage:  "lists the configured networks",
+	Action: cmdNetworksAction,
+}
+
+func cmdNetworksAction(ctx context.Context, cmd *cli.Command) error {
+	for _, net := range networkRegistry.List() {
+		log.Infof("%s:", net.Name)
+		log.Infof(" - Protocol:      %s", net.KadProtocol())
+		log.Infof(" - Namespaces:    %s", strings.Join(net.Namespaces, ", "))
+		log.Infof(" - Bootstrappers: %d", len(net.Bootstrappers))
+		for _, addr := range net.Bootstrappers {
+			log.Infof("   %s", addr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+// writeSnapshot exports the crawl snapshot in the given format to the given path ("-" means stdout)
+func writeSnapshot(snapshot *dht.CrawlSnapshot, format dht.OutputFormat, path string) error {
+	err := writeToPath(path, func(w io.Writer) error {
+		return snapshot.Write(w, format)
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s output: %w", format, err)
+	}
+	log.WithFields(log.Fields{
+		"format": format,
+		"out":    path,
+	}).Info("crawl results exported")
+	return nil
+}
+
+// writeGraph exports the routing table graph in the given format to the given path ("-" means stdout)
+func writeGraph(graph *dht.CrawlGraph, format dht.GraphFormat, path string) error {
+	err := writeToPath(path, func(w io.Writer) error {
+		return graph.Write(w, format)
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s graph: %w", format, err)
+	}
+	log.WithFields(log.Fields{
+		"format": format,
+		"out":    path,
+	}).Info("routing table graph exported")
+	return nil
+}
+
+// writeTraces exports the lookup traces in the given format to the given path ("-" means stdout)
+func writeTraces(traces []*dht.LookupTrace, format dht.TraceFormat, path string) error {
+	err := writeToPath(path, func(w io.Writer) error {
+		return dht.WriteTraces(w, traces, format)
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s trace: %w", format, err)
+	}
+	log.WithFields(log.Fields{
+		"format": format,
+		"out":    path,
+	}).Info("lookup trace exported")
+	return nil
+}
+
+// writeToPath opens the given path ("-" means stdout) and hands it over to the write function
+func writeToPath(path string, write func(w io.Writer) error) error {
+	if path == "-" || path == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+// progressRefresh is how often the progress line is redrawn on a terminal
+const progressRefresh = time.Second
+
+// progressReporter periodically reports the progress of a crawl: as a line that is
+// redrawn in place when the logs go to a terminal, or as log entries otherwise
+type progressReporter struct {
+	tracker     *dht.ProgressTracker
+	interval    time.Duration
+	interactive bool
+	w           io.Writer
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startProgress starts reporting the progress tracked by the given tracker every interval
+func startProgress(tracker *dht.ProgressTracker, interval time.Duration) *progressReporter {
+	r := &progressReporter{
+		tracker:  tracker,
+		interval: interval,
+		w:        log.StandardLogger().Out,
+		stop:     make(chan struct{}),
+	}
+	// the line would be mixed up with JSON logs, so it's only drawn next to text ones
+	if f, ok := r.w.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		_, text := log.StandardLogger().Formatter.(*log.TextFormatter)
+		r.interactive = text
+	}
+	if r.interactive {
+		r.interval = progressRefresh
+	}
+
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+func (r *progressReporter) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// nothing to report while the host is set up and bootstrapped
+			if p := r.tracker.Progress(); p.Started {
+				r.report(p)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop reports the final progress, if the crawl started, and ends the interactive line, if any
+func (r *progressReporter) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+
+	p := r.tracker.Progress()
+	if !p.Started {
+		return
+	}
+	r.report(p)
+	if r.interactive {
+		fmt.Fprintln(r.w)
+	}
+}
+
+func (r *progressReporter) report(p dht.CrawlProgress) {
+	if !r.interactive {
+		log.WithFields(log.Fields{
+			"visited":   p.Visited(),
+			"succeeded": p.Succeeded,
+			"failed":    p.Failed,
+			"providers": p.Providers,
+			"queued":    p.Queued(),
+			"peers/s":   fmt.Sprintf("%.1f", p.PeersPerSecond()),
+			"elapsed":   p.Elapsed.Round(time.Second),
+		}).Info("crawl progress")
+		return
+	}
+
+	line := fmt.Sprintf("crawling: %d visited (%d ok, %d failed) | %d providers | ~%d queued | %.1f peers/s | %s",
+		p.Visited(), p.Succeeded, p.Failed, p.Providers, p.Queued(), p.PeersPerSecond(), p.Elapsed.Round(time.Second))
+	// clear the previous line before drawing the new one
+	fmt.Fprintf(r.w, "\r\033[K%s", line)
+}
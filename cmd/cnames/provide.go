@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+var provideConfig = dht.ProvideCmdConfig{
+	Network:           dht.DefaultNetwork.String(),
+	Identity:          dht.DefaultIdentity,
+	Listen:            dht.DefaultProvideListen,
+	IsCustomNamespace: dht.DefaultIsNamespace,
+	Namespace:         dht.DefaultNamespace.String(),
+	MinRoutingTable:   int64(dht.DefaultLookupMinRoutingTable),
+	BootTimeout:       dht.DefaultLookupBootTimeout,
+	Interval:          dht.DefaultProvideInterval,
+	VerifyMode:        dht.DefaultProvideVerifyMode.String(),
+	VerifyTimeout:     dht.DefaultProvideVerifyTimeout,
+	VerifyInterval:    dht.DefaultProvideVerifyInterval,
+}
+
+var cmdProvide = &cli.Command{
+	Name:   "provide",
+	Usage:  "runs a DHT node that advertises the given namespace until interrupted",
+	Flags:  cmdProvideFlags,
+	Action: cmdProvideAction,
+}
+
+var cmdProvideFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name: "network",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NETWORK")},
+		},
+		Usage:       "celestia network where the cname will run",
+		Value:       provideConfig.Network,
+		Destination: &provideConfig.Network,
+	},
+	identityFlag(&provideConfig.Identity),
+	bootstrapFlag(&provideConfig.Bootstrap),
+	seedFileFlag(&provideConfig.SeedFile),
+	&cli.StringSliceFlag{
+		Name: "listen",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_LISTEN")},
+		},
+		Usage:       "multiaddrs the node listens on (repeatable or comma separated)",
+		Value:       provideConfig.Listen,
+		Destination: &provideConfig.Listen,
+	},
+	&cli.BoolFlag{
+		Name: "is-custom",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_IS_CUSTOM")},
+		},
+		Usage:       "take the namespace as a free-form DHT key instead of a known namespace or alias",
+		Value:       provideConfig.IsCustomNamespace,
+		Destination: &provideConfig.IsCustomNamespace,
+	},
+	&cli.StringFlag{
+		Name: "namespace",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_NAMESPACE")},
+		},
+		Usage:       "namespace or alias (full, archival, legacy-full, legacy-archival) that will be advertised",
+		Value:       provideConfig.Namespace,
+		Destination: &provideConfig.Namespace,
+	},
+	&cli.IntFlag{
+		Name: "min-routing-table",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_MIN_ROUTING_TABLE")},
+		},
+		Usage:       "peers the routing table needs before the first advertisement",
+		Value:       provideConfig.MinRoutingTable,
+		Destination: &provideConfig.MinRoutingTable,
+	},
+	&cli.DurationFlag{
+		Name: "bootstrap-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_BOOTSTRAP_TIMEOUT")},
+		},
+		Usage:       "maximum time to wait for the routing table to fill up before advertising anyway",
+		Value:       provideConfig.BootTimeout,
+		Destination: &provideConfig.BootTimeout,
+	},
+	&cli.DurationFlag{
+		Name: "interval",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_INTERVAL")},
+		},
+		Usage:       "time between re-advertisements of the namespace",
+		Value:       provideConfig.Interval,
+		Destination: &provideConfig.Interval,
+	},
+	&cli.BoolFlag{
+		Name: "once",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_ONCE")},
+		},
+		Usage:       "exit after the first advertisement (and its verification) instead of re-providing",
+		Value:       provideConfig.Once,
+		Destination: &provideConfig.Once,
+	},
+	&cli.BoolFlag{
+		Name: "verify",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_VERIFY")},
+		},
+		Usage:       "confirm that the record propagated after the first advertisement and report the time to visibility",
+		Value:       provideConfig.Verify,
+		Destination: &provideConfig.Verify,
+	},
+	&cli.StringFlag{
+		Name: "verify-mode",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_VERIFY_MODE")},
+		},
+		Usage:       "lookup asks the peers closest to the namespace for the record, crawl asks every peer in the network",
+		Value:       provideConfig.VerifyMode,
+		Destination: &provideConfig.VerifyMode,
+	},
+	&cli.DurationFlag{
+		Name: "verify-timeout",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_VERIFY_TIMEOUT")},
+		},
+		Usage:       "time the record has to become visible",
+		Value:       provideConfig.VerifyTimeout,
+		Destination: &provideConfig.VerifyTimeout,
+	},
+	&cli.DurationFlag{
+		Name: "verify-interval",
+		Sources: cli.ValueSourceChain{
+			Chain: []cli.ValueSource{cli.EnvVar("CNAMES_PROVIDE_VERIFY_INTERVAL")},
+		},
+		Usage:       "time between verification attempts",
+		Value:       provideConfig.VerifyInterval,
+		Destination: &provideConfig.VerifyInterval,
+	},
+}
+
+func cmdProvideAction(ctx context.Context, cmd *cli.Command) error {
+	log.WithFields(log.Fields{
+		"network":      provideConfig.Network,
+		"listen":       provideConfig.Listen,
+		"is-custom-ns": provideConfig.IsCustomNamespace,
+		"namespace":    provideConfig.Namespace,
+		"min-rt-size":  provideConfig.MinRoutingTable,
+		"boot-timeout": provideConfig.BootTimeout,
+		"interval":     provideConfig.Interval,
+		"once":         provideConfig.Once,
+		"verify":       provideConfig.Verify,
+		"verify-mode":  provideConfig.VerifyMode,
+	}).Info("starting cnames-provide...")
+
+	if provideConfig.Interval <= 0 || provideConfig.BootTimeout <= 0 {
+		return fmt.Errorf("interval and bootstrap-timeout must be positive")
+	}
+	if provideConfig.VerifyTimeout <= 0 || provideConfig.VerifyInterval <= 0 {
+		return fmt.Errorf("verify-timeout and verify-interval must be positive")
+	}
+	verifyMode, err := dht.VerifyModeFromString(provideConfig.VerifyMode)
+	if err != nil {
+		return err
+	}
+
+	netConf, err := networkRegistry.Get(provideConfig.Network)
+	if err != nil {
+		return err
+	}
+	namespaces, err := netConf.ResolveNamespaces([]string{provideConfig.Namespace}, provideConfig.IsCustomNamespace)
+	if err != nil {
+		return err
+	}
+	if len(namespaces) > 1 {
+		return fmt.Errorf("provide takes a single namespace, %q resolves to %d", provideConfig.Namespace, len(namespaces))
+	}
+	namespace := namespaces[0]
+	bootstrappers, err := bootstrapPeers(netConf, provideConfig.Bootstrap, provideConfig.SeedFile)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := loadIdentity(provideConfig.Identity)
+	if err != nil {
+		return err
+	}
+	client, err := dht.NewClient(netConf,
+		dht.WithIdentity(privKey),
+		dht.WithBootstrappers(bootstrappers),
+		dht.WithServerMode(),
+		dht.WithHostOptions(libp2p.ListenAddrStrings(provideConfig.Listen...)),
+	)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	boot, err := client.Bootstrap(ctx)
+	if err != nil {
+		return err
+	}
+	for p, err := range boot.Failed {
+		log.Warn("couldn't connect to ", p, ": ", err)
+	}
+
+	log.Info("HOST info:")
+	log.Info("- Peer ID:			", client.ID())
+	log.Info("- Network:			", netConf.Network())
+	log.Info("- Agent Version:		", dht.CustomUserAgent)
+	log.Info("- Bootnodes:			", len(boot.Connected))
+	for _, addr := range client.Host().Addrs() {
+		log.Infof("- Listening on:		%s/p2p/%s", addr, client.ID())
+	}
+
+	ticker := time.NewTicker(provideConfig.Interval)
+	defer ticker.Stop()
+	for round := 1; ; round++ {
+		start := time.Now()
+		report, err := client.Provide(ctx, dht.ProvideOptions{
+			Namespace:        namespace,
+			TTL:              provideConfig.Interval,
+			MinRoutingTable:  int(provideConfig.MinRoutingTable),
+			BootstrapTimeout: provideConfig.BootTimeout,
+		})
+		if err != nil {
+			if provideConfig.Once || ctx.Err() != nil {
+				return err
+			}
+			log.WithError(err).Warnf("couldn't advertise %s, retrying in %s", namespace, provideConfig.Interval)
+		} else {
+			if round == 1 {
+				if !report.Ready {
+					log.Warnf("routing table only reached %d of %d peers after %s, advertising anyway", report.RoutingTableSize, provideConfig.MinRoutingTable, report.BootstrapTime.Round(time.Millisecond))
+				}
+				log.Info("- Routing table size:	", report.RoutingTableSize)
+			}
+			log.WithFields(log.Fields{
+				"round":     round,
+				"namespace": namespace,
+				"took":      report.Took.Round(time.Millisecond),
+				"ttl":       report.TTL,
+			}).Info("namespace advertised")
+
+			if provideConfig.Verify && round == 1 {
+				err := verifyProvide(ctx, verifyMode, netConf, bootstrappers, namespace, client.ID(), start)
+				if err != nil && provideConfig.Once {
+					return err
+				} else if err != nil {
+					log.WithError(err).Error("verification failed")
+				}
+			}
+		}
+
+		if provideConfig.Once {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Info("stopping the provider")
+			return nil
+		}
+	}
+}
+
+// verifyProvide checks from a separate client that the provider record can be found in the network,
+// reporting the time since the advertisement started until the record became visible
+func verifyProvide(ctx context.Context, mode dht.VerifyMode, netConf *dht.NetworkConfig, bootstrappers []peer.AddrInfo, namespace string, provider peer.ID, start time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, provideConfig.VerifyTimeout)
+	defer cancel()
+
+	// an ephemeral identity, so the verifier is never mistaken for the provider
+	verifier, err := dht.NewClient(netConf, dht.WithBootstrappers(bootstrappers))
+	if err != nil {
+		return err
+	}
+	defer verifier.Close()
+	log.WithFields(log.Fields{
+		"mode":     mode,
+		"verifier": verifier.ID(),
+	}).Info("verifying the provider record...")
+
+	var (
+		holders  []peer.ID
+		attempts int
+	)
+	switch mode {
+	case dht.VerifyCrawl:
+		holders, attempts, err = verifyByCrawl(ctx, verifier, namespace, provider)
+	default:
+		holders, attempts, err = verifier.WaitForRecordHolders(ctx, dht.RecordHoldersOptions{
+			Namespace:        namespace,
+			Provider:         provider,
+			Interval:         provideConfig.VerifyInterval,
+			MinRoutingTable:  int(provideConfig.MinRoutingTable),
+			BootstrapTimeout: provideConfig.BootTimeout,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"namespace":          namespace,
+		"time-to-visibility": time.Since(start).Round(time.Millisecond),
+		"attempts":           attempts,
+		"holders":            len(holders),
+	}).Info("provider record visible")
+	for _, holder := range holders {
+		log.Infof(" - held by %s", holder)
+	}
+	return nil
+}
+
+// verifyByCrawl crawls the network until any peer, other than the provider, holds the record
+func verifyByCrawl(ctx context.Context, verifier *dht.Client, namespace string, provider peer.ID) ([]peer.ID, int, error) {
+	for attempt := 1; ; attempt++ {
+		attemptStart := time.Now()
+		report, err := verifier.Crawl(ctx, dht.CrawlOptions{
+			Namespaces:  []string{namespace},
+			ProvTimeout: provideConfig.VerifyInterval,
+		})
+		if err != nil {
+			return nil, attempt, err
+		}
+		results := report.Results
+
+		holders := make([]peer.ID, 0)
+		for _, holder := range results.GetProvHolders(namespace)[provider] {
+			if holder != provider {
+				holders = append(holders, holder)
+			}
+		}
+		if len(holders) > 0 {
+			return holders, attempt, nil
+		}
+		log.WithFields(log.Fields{
+			"attempt": attempt,
+			"crawled": len(results.GetSuccPeers()),
+		}).Info("provider record not visible yet")
+
+		select {
+		case <-time.After(provideConfig.VerifyInterval - time.Since(attemptStart)):
+		case <-ctx.Done():
+			return nil, attempt, fmt.Errorf("provider %s not visible for %s: %w", provider, namespace, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// dumpSignals is empty, as there is no SIGUSR1 outside of unix systems
+var dumpSignals []os.Signal
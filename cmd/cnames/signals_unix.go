@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals make a running crawl export a snapshot of its results without stopping
+var dumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,208 @@
+// Package devnet starts a local Celestia DHT network, with in-process libp2p hosts
+// listening on the loopback interface, to test cnames without the live networks
+package devnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	kad "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	routingdisc "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+const (
+	// Network is the network the devnet emulates, all hosts speak its DHT protocol
+	Network = dht.Private
+
+	DefaultNodes      = 10
+	DefaultListenAddr = "/ip4/127.0.0.1/tcp/0"
+
+	// AgentVersion is the agent version of the devnet hosts
+	AgentVersion = "cnames-devnet"
+
+	// refreshTimeout bounds the initial routing table refresh of each host
+	refreshTimeout = 30 * time.Second
+)
+
+// Node is a single host of the devnet, running a DHT server
+type Node struct {
+	Host host.Host
+	DHT  *kad.IpfsDHT
+	// Namespaces are the namespaces the node advertises
+	Namespaces []string
+}
+
+// AddrInfo returns the ID and listen addresses of the node
+func (n *Node) AddrInfo() peer.AddrInfo {
+	return peer.AddrInfo{ID: n.Host.ID(), Addrs: n.Host.Addrs()}
+}
+
+// Devnet is a running set of DHT nodes connected to each other
+type Devnet struct {
+	Nodes []*Node
+}
+
+// New starts the devnet: it creates the hosts, connects them into a single DHT,
+// waits for their routing tables to be populated and advertises the namespaces
+func New(ctx context.Context, opts ...Option) (*Devnet, error) {
+	o := new(options)
+	for _, opt := range append([]Option{defaultOptions}, opts...) {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	for namespace, count := range o.providers {
+		if count > o.nodes {
+			return nil, fmt.Errorf("%d providers of %s requested, but the devnet only has %d nodes", count, namespace, o.nodes)
+		}
+	}
+
+	d := &Devnet{Nodes: make([]*Node, 0, o.nodes)}
+	for i := 0; i < o.nodes; i++ {
+		node, err := newNode(ctx, o.listenAddr)
+		if err != nil {
+			_ = d.Close()
+			return nil, fmt.Errorf("starting node %d: %w", i, err)
+		}
+		d.Nodes = append(d.Nodes, node)
+	}
+
+	if err := d.connect(ctx); err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	if err := d.advertise(ctx, o.providers); err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func newNode(ctx context.Context, listenAddr string) (*Node, error) {
+	privKey, err := dht.LoadIdentity(dht.IdentityEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	h, err := libp2p.New(
+		libp2p.UserAgent(AgentVersion),
+		libp2p.Identity(privKey),
+		libp2p.ListenAddrStrings(listenAddr),
+		libp2p.DisableRelay(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	dhtNode, err := kad.New(ctx, h,
+		kad.Mode(kad.ModeServer),
+		kad.ProtocolPrefix(Network.KadPrefix()),
+	)
+	if err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return &Node{
+		Host:       h,
+		DHT:        dhtNode,
+		Namespaces: make([]string, 0),
+	}, nil
+}
+
+// connect links every node to the first one and to the previous one, and lets
+// the routing table refresh discover the rest of the network
+func (d *Devnet) connect(ctx context.Context) error {
+	for i, node := range d.Nodes[1:] {
+		for _, other := range []*Node{d.Nodes[0], d.Nodes[i]} {
+			if err := node.Host.Connect(ctx, other.AddrInfo()); err != nil {
+				return fmt.Errorf("connecting %s to %s: %w", node.Host.ID(), other.Host.ID(), err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+	for _, node := range d.Nodes {
+		select {
+		case err := <-node.DHT.RefreshRoutingTable():
+			if err != nil && len(d.Nodes) > 1 {
+				return fmt.Errorf("refreshing the routing table of %s: %w", node.Host.ID(), err)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("refreshing the routing table of %s: %w", node.Host.ID(), ctx.Err())
+		}
+	}
+	return nil
+}
+
+// advertise makes the first count nodes provide each namespace
+func (d *Devnet) advertise(ctx context.Context, providers map[string]int) error {
+	namespaces := make([]string, 0, len(providers))
+	for namespace := range providers {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		for _, node := range d.Nodes[:providers[namespace]] {
+			disc := routingdisc.NewRoutingDiscovery(node.DHT)
+			if _, err := disc.Advertise(ctx, namespace); err != nil {
+				return fmt.Errorf("advertising %s from %s: %w", namespace, node.Host.ID(), err)
+			}
+			node.Namespaces = append(node.Namespaces, namespace)
+		}
+	}
+	return nil
+}
+
+// Providers returns the IDs of the nodes advertising the namespace
+func (d *Devnet) Providers(namespace string) []peer.ID {
+	providers := make([]peer.ID, 0)
+	for _, node := range d.Nodes {
+		for _, ns := range node.Namespaces {
+			if ns == namespace {
+				providers = append(providers, node.Host.ID())
+			}
+		}
+	}
+	return providers
+}
+
+// BootstrapPeers returns the address of every node, to be used as bootstrappers of the devnet
+func (d *Devnet) BootstrapPeers() []peer.AddrInfo {
+	peers := make([]peer.AddrInfo, 0, len(d.Nodes))
+	for _, node := range d.Nodes {
+		peers = append(peers, node.AddrInfo())
+	}
+	return peers
+}
+
+// BootstrapAddrs returns the /p2p/ multiaddrs of every node, as taken by --bootstrap and seed files
+func (d *Devnet) BootstrapAddrs() ([]string, error) {
+	addrs := make([]string, 0, len(d.Nodes))
+	for _, node := range d.Nodes {
+		p2pAddrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: node.Host.ID(), Addrs: node.Host.Addrs()})
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range p2pAddrs {
+			addrs = append(addrs, addr.String())
+		}
+	}
+	return addrs, nil
+}
+
+// Close stops every node of the devnet
+func (d *Devnet) Close() error {
+	var errs []error
+	for _, node := range d.Nodes {
+		errs = append(errs, node.DHT.Close(), node.Host.Close())
+	}
+	return errors.Join(errs...)
+}
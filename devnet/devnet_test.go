@@ -0,0 +1,94 @@
+package devnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	routingdisc "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+)
+
+func startDevnet(t *testing.T, opts ...Option) *Devnet {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping devnet test in short mode")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	d, err := New(ctx, opts...)
+	if err != nil {
+		t.Fatalf("starting the devnet: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := d.Close(); err != nil {
+			t.Errorf("closing the devnet: %v", err)
+		}
+	})
+	return d
+}
+
+func TestDevnet(t *testing.T) {
+	d := startDevnet(t, WithNodes(6), WithProviders("/full/v0.1.0", 2), WithProviders("archival", 1))
+
+	if len(d.Nodes) != 6 {
+		t.Fatalf("expected 6 nodes, got %d", len(d.Nodes))
+	}
+	for _, node := range d.Nodes {
+		if node.DHT.RoutingTable().Size() == 0 {
+			t.Errorf("node %s has an empty routing table", node.Host.ID())
+		}
+	}
+	if got := len(d.Providers("/full/v0.1.0")); got != 2 {
+		t.Errorf("expected 2 providers of /full/v0.1.0, got %d", got)
+	}
+	if got := len(d.Providers("archival")); got != 1 {
+		t.Errorf("expected 1 provider of archival, got %d", got)
+	}
+
+	addrs, err := d.BootstrapAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 6 {
+		t.Errorf("expected one bootstrap address per node, got %v", addrs)
+	}
+
+	// the last node doesn't provide anything, but can find the providers through the DHT
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	disc := routingdisc.NewRoutingDiscovery(d.Nodes[len(d.Nodes)-1].DHT)
+	peers, err := disc.FindPeers(ctx, "/full/v0.1.0", discovery.Limit(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for range peers {
+		found++
+	}
+	if found != 2 {
+		t.Errorf("expected to find 2 providers, found %d", found)
+	}
+}
+
+func TestDevnetOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "no nodes", opts: []Option{WithNodes(0)}},
+		{name: "invalid listen address", opts: []Option{WithListenAddr("127.0.0.1:0")}},
+		{name: "negative providers", opts: []Option{WithProviders("full", -1)}},
+		{name: "more providers than nodes", opts: []Option{WithNodes(2), WithProviders("full", 3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(context.Background(), tt.opts...)
+			if err == nil {
+				_ = d.Close()
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
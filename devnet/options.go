@@ -0,0 +1,61 @@
+package devnet
+
+import (
+	"fmt"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Option is a functional option to tune the Devnet
+type Option func(*options) error
+
+type options struct {
+	nodes      int
+	listenAddr string
+	providers  map[string]int
+}
+
+// defaultOptions are always applied before the user-given options
+var defaultOptions = func(o *options) error {
+	o.nodes = DefaultNodes
+	o.listenAddr = DefaultListenAddr
+	o.providers = make(map[string]int)
+	return nil
+}
+
+// WithNodes defines the number of hosts in the devnet
+func WithNodes(nodes int) Option {
+	return func(o *options) error {
+		if nodes <= 0 {
+			return fmt.Errorf("nodes has to be greater than 0, got %d", nodes)
+		}
+		o.nodes = nodes
+		return nil
+	}
+}
+
+// WithListenAddr defines the multiaddr the hosts listen on, which should use port 0
+func WithListenAddr(addr string) Option {
+	return func(o *options) error {
+		if _, err := ma.NewMultiaddr(addr); err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", addr, err)
+		}
+		o.listenAddr = addr
+		return nil
+	}
+}
+
+// WithProviders makes the given number of hosts advertise the namespace. It can be given
+// several times, for different namespaces
+func WithProviders(namespace string, count int) Option {
+	return func(o *options) error {
+		if namespace == "" {
+			return fmt.Errorf("empty namespace")
+		}
+		if count < 0 {
+			return fmt.Errorf("providers of %s can't be negative, got %d", namespace, count)
+		}
+		o.providers[namespace] = count
+		return nil
+	}
+}
@@ -0,0 +1,197 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+// maxResolveDepth limits the chain of /dnsaddr records that are followed while resolving an address
+const maxResolveDepth = 4
+
+// Resolver resolves /dns, /dns4, /dns6 and /dnsaddr multiaddrs.
+// *madns.Resolver implements it, so a resolver with a mock backend can be used to run the checks offline
+type Resolver interface {
+	Resolve(ctx context.Context, maddr ma.Multiaddr) ([]ma.Multiaddr, error)
+}
+
+// BootstrapCheck is the health report of a single bootstrap entry
+type BootstrapCheck struct {
+	Entry string
+	ID    peer.ID
+	// Resolved are the dialable addresses the entry resolved to
+	Resolved   []ma.Multiaddr
+	ResolveErr error
+	Dials      []DialCheck
+}
+
+// Healthy is true if the peer could be dialed and identified over at least one address
+// and it supports the DHT protocol of the network
+func (c *BootstrapCheck) Healthy() bool {
+	for _, dial := range c.Dials {
+		if dial.Healthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// DialCheck is the outcome of dialing a bootstrapper over a single resolved address
+type DialCheck struct {
+	Addr      ma.Multiaddr
+	Transport string
+	// DialLatency is the time to establish the connection
+	DialLatency time.Duration
+	// IdentifyLatency is the time for identify to complete once connected
+	IdentifyLatency time.Duration
+	AgentVersion    string
+	SupportsKad     bool
+	Err             error
+}
+
+func (d *DialCheck) Healthy() bool { return d.Err == nil && d.SupportsKad }
+
+// BootstrapChecker checks the health of the bootstrappers of a network
+type BootstrapChecker struct {
+	h           host.Host
+	resolver    Resolver
+	kadProtocol protocol.ID
+	timeout     time.Duration
+}
+
+// NewBootstrapChecker creates a checker that dials from the given host. A nil resolver uses the system DNS
+func NewBootstrapChecker(h host.Host, resolver Resolver, kadProtocol protocol.ID, timeout time.Duration) *BootstrapChecker {
+	if resolver == nil {
+		resolver = madns.DefaultResolver
+	}
+	return &BootstrapChecker{
+		h:           h,
+		resolver:    resolver,
+		kadProtocol: kadProtocol,
+		timeout:     timeout,
+	}
+}
+
+// Check resolves and dials each of the given bootstrap entries sequentially
+func (c *BootstrapChecker) Check(ctx context.Context, entries []string) ([]*BootstrapCheck, error) {
+	checks := make([]*BootstrapCheck, 0, len(entries))
+	for _, entry := range entries {
+		ai, err := peer.AddrInfoFromString(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap address %q: %w", entry, err)
+		}
+
+		check := &BootstrapCheck{Entry: entry, ID: ai.ID}
+		check.Resolved, check.ResolveErr = c.resolve(ctx, ai.Addrs)
+		for _, addr := range check.Resolved {
+			if ctx.Err() != nil {
+				return checks, ctx.Err()
+			}
+			check.Dials = append(check.Dials, c.dial(ctx, ai.ID, addr))
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// resolve expands the DNS addresses until only dialable ones are left
+func (c *BootstrapChecker) resolve(ctx context.Context, addrs []ma.Multiaddr) ([]ma.Multiaddr, error) {
+	resolved := make([]ma.Multiaddr, 0)
+	pending := addrs
+	for depth := 0; len(pending) > 0; depth++ {
+		if depth > maxResolveDepth {
+			return resolved, fmt.Errorf("too many nested dnsaddr records")
+		}
+
+		next := make([]ma.Multiaddr, 0)
+		for _, addr := range pending {
+			if !madns.Matches(addr) {
+				resolved = append(resolved, addr)
+				continue
+			}
+			rctx, cancel := context.WithTimeout(ctx, c.timeout)
+			addrs, err := c.resolver.Resolve(rctx, addr)
+			cancel()
+			if err != nil {
+				return resolved, fmt.Errorf("resolving %s: %w", addr, err)
+			}
+			next = append(next, addrs...)
+		}
+		pending = next
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no addresses")
+	}
+
+	// drop the trailing /p2p/ component, as the peer ID is already known
+	for i, addr := range resolved {
+		resolved[i], _ = peer.SplitAddr(addr)
+	}
+	return ma.Unique(resolved), nil
+}
+
+// dial connects to the peer over a single address, waiting for identify to complete
+func (c *BootstrapChecker) dial(ctx context.Context, p peer.ID, addr ma.Multiaddr) DialCheck {
+	check := DialCheck{Addr: addr, Transport: TransportName(addr)}
+
+	// make sure that only the given address is dialed
+	_ = c.h.Network().ClosePeer(p)
+	c.h.Peerstore().ClearAddrs(p)
+	c.h.Peerstore().AddAddr(p, addr, peerstore.TempAddrTTL)
+	defer func() { _ = c.h.Network().ClosePeer(p) }()
+
+	dctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := c.h.Network().DialPeer(dctx, p)
+	check.DialLatency = time.Since(start)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+
+	start = time.Now()
+	if idHost, ok := c.h.(interface{ IDService() identify.IDService }); ok {
+		select {
+		case <-idHost.IDService().IdentifyWait(conn):
+		case <-dctx.Done():
+			check.Err = fmt.Errorf("identify failed to complete: %w", asResponseTimeout(dctx.Err()))
+			return check
+		}
+	}
+	check.IdentifyLatency = time.Since(start)
+
+	if av, err := c.h.Peerstore().Get(p, "AgentVersion"); err == nil {
+		check.AgentVersion, _ = av.(string)
+	}
+	if prots, err := c.h.Peerstore().SupportsProtocols(p, c.kadProtocol); err == nil {
+		check.SupportsKad = len(prots) > 0
+	}
+	if !check.SupportsKad {
+		check.Err = fmt.Errorf("protocol %s not supported", c.kadProtocol)
+	}
+	return check
+}
+
+// TransportName returns the transport part of the multiaddr, e.g. tcp or udp/quic-v1
+func TransportName(addr ma.Multiaddr) string {
+	parts := make([]string, 0)
+	for _, proto := range addr.Protocols() {
+		switch proto.Code {
+		case ma.P_IP4, ma.P_IP6, ma.P_DNS, ma.P_DNS4, ma.P_DNS6, ma.P_DNSADDR, ma.P_P2P, ma.P_CERTHASH, ma.P_SNI:
+			continue
+		}
+		parts = append(parts, proto.Name)
+	}
+	return strings.Join(parts, "/")
+}
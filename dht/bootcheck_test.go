@@ -0,0 +1,117 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+// failingResolver fails every lookup, like an unreachable DNS server
+type failingResolver struct{}
+
+func (failingResolver) LookupIPAddr(context.Context, string) ([]net.IPAddr, error) {
+	return nil, errors.New("server misbehaving")
+}
+
+func (failingResolver) LookupTXT(context.Context, string) ([]string, error) {
+	return nil, errors.New("server misbehaving")
+}
+
+func TestCheckBootstrappersResolver(t *testing.T) {
+	netConf := &NetworkConfig{Name: "test", ProtocolPrefix: "/celestia/test"}
+
+	// the bootstrapper only listens on TCP and speaks the DHT protocol of the network
+	boot, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"), libp2p.DisableRelay())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boot.Close()
+	boot.SetStreamHandler(netConf.KadProtocol(), func(s network.Stream) { _ = s.Reset() })
+	tcp := boot.Addrs()[0]
+	port, err := tcp.ValueForProtocol(ma.P_TCP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := boot.ID().String()
+
+	resolver, err := madns.NewResolver(
+		madns.WithDefaultResolver(&madns.MockResolver{
+			IP: map[string][]net.IPAddr{"node.test": {{IP: net.ParseIP("127.0.0.1")}}},
+			TXT: map[string][]string{
+				"_dnsaddr.boot.test":   {"dnsaddr=/dnsaddr/nested.test/p2p/" + id},
+				"_dnsaddr.nested.test": {"dnsaddr=" + tcp.String() + "/p2p/" + id},
+				// every record points to itself again
+				"_dnsaddr.loop.test": {"dnsaddr=/dnsaddr/loop.test/p2p/" + id},
+			},
+		}),
+		madns.WithDomainResolver("broken.test", failingResolver{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(netConf, WithResolver(resolver), WithHostOptions(libp2p.NoListenAddrs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name     string
+		entry    string
+		resolved []string
+		// err is a substring of the expected resolve error, if any
+		err string
+	}{
+		{name: "nested dnsaddr", entry: "/dnsaddr/boot.test/p2p/" + id, resolved: []string{tcp.String()}},
+		{name: "dns4", entry: "/dns4/node.test/tcp/" + port + "/p2p/" + id, resolved: []string{tcp.String()}},
+		{name: "failed resolve", entry: "/dns4/node.broken.test/tcp/" + port + "/p2p/" + id, err: "server misbehaving"},
+		{name: "unknown domain", entry: "/dnsaddr/missing.test/p2p/" + id, err: "no addresses"},
+		{name: "too deep", entry: "/dnsaddr/loop.test/p2p/" + id, err: "too many nested dnsaddr records"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			checks, err := client.CheckBootstrappers(ctx, []string{tt.entry}, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(checks) != 1 {
+				t.Fatalf("expected a single check, got %d", len(checks))
+			}
+			check := checks[0]
+
+			if tt.err != "" {
+				if check.ResolveErr == nil || !strings.Contains(check.ResolveErr.Error(), tt.err) {
+					t.Errorf("expected a resolve error with %q, got %v", tt.err, check.ResolveErr)
+				}
+				if check.Healthy() || len(check.Dials) != 0 {
+					t.Errorf("expected an unhealthy bootstrapper that wasn't dialed, got %+v", check)
+				}
+				return
+			}
+
+			resolved := make([]string, len(check.Resolved))
+			for i, addr := range check.Resolved {
+				resolved[i] = addr.String()
+			}
+			if check.ResolveErr != nil || !slices.Equal(resolved, tt.resolved) {
+				t.Errorf("expected the addresses %v, got %v (%v)", tt.resolved, resolved, check.ResolveErr)
+			}
+			if !check.Healthy() {
+				t.Errorf("expected a healthy bootstrapper, got dials %+v", check.Dials)
+			}
+		})
+	}
+}
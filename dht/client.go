@@ -0,0 +1,451 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	kad "github.com/libp2p/go-libp2p-kad-dht"
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	routingdisc "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+)
+
+// Client is a libp2p host joined to a Celestia network that can crawl it and look namespaces up.
+// It returns typed results and leaves any logging or printing to the caller
+type Client struct {
+	netConf       *NetworkConfig
+	h             host.Host
+	bootstrappers []peer.AddrInfo
+	serverMode    bool
+	resolver      Resolver
+
+	m         sync.Mutex
+	dhtCli    *kad.IpfsDHT
+	disc      *routingdisc.RoutingDiscovery
+	bootstrap *BootstrapReport
+}
+
+// BootstrapReport lists the bootstrappers the client could and couldn't connect to
+type BootstrapReport struct {
+	Connected []peer.ID
+	Failed    map[peer.ID]error
+}
+
+// NewClient creates the host of the client for the given network. The DHT node used by the
+// lookups and advertisements is only created (and bootstrapped) on the first call that needs it
+func NewClient(netConf *NetworkConfig, opts ...ClientOption) (*Client, error) {
+	o := new(clientOptions)
+	if err := defaultClientOptions(o); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	bootstrappers := o.bootstrappers
+	if len(bootstrappers) == 0 {
+		var err error
+		bootstrappers, err = netConf.BootstrapPeers()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hostOpts := []libp2p.Option{
+		libp2p.UserAgent(o.userAgent),
+		libp2p.DisableRelay(),
+	}
+	if o.privKey != nil {
+		hostOpts = append(hostOpts, libp2p.Identity(o.privKey))
+	}
+	h, err := libp2p.New(append(hostOpts, o.hostOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		netConf:       netConf,
+		h:             h,
+		bootstrappers: bootstrappers,
+		serverMode:    o.serverMode,
+		resolver:      o.resolver,
+	}, nil
+}
+
+// ID returns the peer ID of the client host
+func (c *Client) ID() peer.ID { return c.h.ID() }
+
+// Host returns the libp2p host of the client
+func (c *Client) Host() host.Host { return c.h }
+
+// Network returns the network the client joins
+func (c *Client) Network() *NetworkConfig { return c.netConf }
+
+// Bootstrappers returns the peers the client uses to join the network
+func (c *Client) Bootstrappers() []peer.AddrInfo {
+	return append([]peer.AddrInfo{}, c.bootstrappers...)
+}
+
+// Close stops the DHT client, if any, and the host
+func (c *Client) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var errs []error
+	if c.dhtCli != nil {
+		errs = append(errs, c.dhtCli.Close())
+	}
+	errs = append(errs, c.h.Close())
+	return errors.Join(errs...)
+}
+
+// CrawlOptions tune a single crawl. Zero values fall back to the crawl defaults
+type CrawlOptions struct {
+	// Namespaces are the DHT keys whose providers are searched (the ones of the network if empty)
+	Namespaces     []string
+	Mode           CrawlMode
+	Parallelism    int
+	ConnectTimeout time.Duration
+	MsgTimeout     time.Duration
+	// ProvTimeout bounds each provider query, which the crawled peers may take longer to answer
+	ProvTimeout time.Duration
+	MaxPeers    int
+	TimeBudget  time.Duration
+	// Retries are the extra attempts of each failed provider query, the FIND_NODE requests aren't retried
+	Retries int
+	// Observer, if not nil, receives the events of the crawl while it runs
+	Observer CrawlObserver
+}
+
+// CrawlReport is the outcome of a crawl. Neighborhoods is only set in CrawlModeNeighborhood
+type CrawlReport struct {
+	Mode          CrawlMode
+	Results       *CrawlResults
+	Neighborhoods []*NeighborhoodTrace
+}
+
+// Crawl walks the network from the bootstrappers asking every visited peer for the providers of the namespaces
+func (c *Client) Crawl(ctx context.Context, opts CrawlOptions) (*CrawlReport, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = DefaultCrawlMode
+	}
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		var err error
+		namespaces, err = c.netConf.ResolveNamespaces(c.netConf.Namespaces, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	provTimeout := opts.ProvTimeout
+	if provTimeout == 0 {
+		provTimeout = DefaultCrawlProvTimeout
+	}
+	if provTimeout < 0 {
+		return nil, fmt.Errorf("provider timeout can't be negative, got %s", provTimeout)
+	}
+	crawlerOpts := []CrawlerOption{
+		WithMaxPeers(opts.MaxPeers),
+		WithTimeBudget(opts.TimeBudget),
+		WithRetries(opts.Retries),
+	}
+	if opts.Parallelism != 0 {
+		crawlerOpts = append(crawlerOpts, WithParallelism(opts.Parallelism))
+	}
+	if opts.ConnectTimeout != 0 {
+		crawlerOpts = append(crawlerOpts, WithConnectTimeout(opts.ConnectTimeout))
+	}
+	if opts.MsgTimeout != 0 {
+		crawlerOpts = append(crawlerOpts, WithMsgTimeout(opts.MsgTimeout))
+	}
+	if opts.Observer != nil {
+		crawlerOpts = append(crawlerOpts, WithObserver(opts.Observer))
+	}
+
+	// protocol messenger for the provider queries
+	prots := []protocol.ID{c.netConf.KadProtocol()}
+	pm, err := pb.NewProtocolMessenger(&MessageSender{H: c.h, Protocols: prots, Timeout: provTimeout})
+	if err != nil {
+		return nil, err
+	}
+	// the crawler isn't closed, as it would close the host of the client. The official
+	// crawler behind it is only created by a full crawl
+	crawler, err := New(c.h, prots, pm, crawlerOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	startingPeers := make([]*peer.AddrInfo, len(c.bootstrappers))
+	for i := range c.bootstrappers {
+		startingPeers[i] = &c.bootstrappers[i]
+	}
+
+	report := &CrawlReport{Mode: mode}
+	switch mode {
+	case CrawlModeNeighborhood:
+		report.Results, report.Neighborhoods = crawler.RunNeighborhood(ctx, startingPeers, namespaces)
+	case CrawlModeFull:
+		report.Results = crawler.Run(ctx, startingPeers, namespaces)
+	default:
+		return nil, fmt.Errorf("unknown crawl mode %q", mode)
+	}
+	if report.Results == nil {
+		return nil, fmt.Errorf("the %s crawl couldn't start", mode)
+	}
+	return report, nil
+}
+
+// Bootstrap connects to the bootstrappers and bootstraps the DHT node of the lookups and
+// advertisements. It only does so once, later calls return the report of the first one
+func (c *Client) Bootstrap(ctx context.Context) (*BootstrapReport, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.bootstrap != nil {
+		return c.bootstrap, nil
+	}
+
+	if c.dhtCli == nil {
+		mode := kad.ModeClient
+		if c.serverMode {
+			mode = kad.ModeServer
+		}
+		dhtCli, err := kad.New(ctx, c.h,
+			kad.Mode(mode),
+			kad.BootstrapPeers(c.bootstrappers...),
+			kad.ProtocolPrefix(c.netConf.KadPrefix()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		c.dhtCli = dhtCli
+		c.disc = routingdisc.NewRoutingDiscovery(dhtCli)
+	}
+
+	report := &BootstrapReport{
+		Connected: make([]peer.ID, 0, len(c.bootstrappers)),
+		Failed:    make(map[peer.ID]error),
+	}
+	for _, bootstrapper := range c.bootstrappers {
+		if err := c.h.Connect(ctx, bootstrapper); err != nil {
+			report.Failed[bootstrapper.ID] = err
+		} else {
+			report.Connected = append(report.Connected, bootstrapper.ID)
+		}
+	}
+	if err := c.dhtCli.Bootstrap(ctx); err != nil {
+		return nil, err
+	}
+	c.bootstrap = report
+	return report, nil
+}
+
+// LookupOptions tune a single lookup. Zero values fall back to the lookup defaults
+type LookupOptions struct {
+	Namespace string
+	// MinRoutingTable is the number of peers the routing table needs before the lookup starts
+	MinRoutingTable int
+	// BootstrapTimeout bounds the wait for the routing table, the lookup starts anyway once it expires
+	BootstrapTimeout time.Duration
+	FindTimeout      time.Duration
+	// Trace records every peer queried during the lookup
+	Trace bool
+	// OnReady, if not nil, is called once the wait for the routing table is over, before the lookup
+	// starts. Only the routing table fields of the given report are set
+	OnReady func(*LookupReport)
+	// OnProvider, if not nil, is called as soon as each provider is found
+	OnProvider func(FoundProvider)
+}
+
+// LookupReport is the outcome of a lookup. Trace is only set if it was requested
+type LookupReport struct {
+	Result *LookupResult
+	Trace  *LookupTrace
+	// RoutingTableSize is the size of the routing table when the lookup started
+	RoutingTableSize int
+	// BootstrapTime is the time spent waiting for the routing table
+	BootstrapTime time.Duration
+	// Ready is false if the routing table didn't reach MinRoutingTable before the bootstrap timeout
+	Ready bool
+}
+
+// Lookup bootstraps the client if needed, waits for its routing table and searches the providers of the namespace
+func (c *Client) Lookup(ctx context.Context, opts LookupOptions) (*LookupReport, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("lookup without namespace")
+	}
+	minRoutingTable := opts.MinRoutingTable
+	if minRoutingTable == 0 {
+		minRoutingTable = DefaultLookupMinRoutingTable
+	}
+	bootTimeout := opts.BootstrapTimeout
+	if bootTimeout == 0 {
+		bootTimeout = DefaultLookupBootTimeout
+	}
+	findTimeout := opts.FindTimeout
+	if findTimeout == 0 {
+		findTimeout = DefaultLookupFindTimeout
+	}
+	if bootTimeout < 0 || findTimeout < 0 {
+		return nil, fmt.Errorf("bootstrap and find timeouts can't be negative")
+	}
+
+	report := new(LookupReport)
+	var err error
+	report.RoutingTableSize, report.BootstrapTime, report.Ready, err = c.waitRoutingTable(ctx, minRoutingTable, bootTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OnReady != nil {
+		opts.OnReady(report)
+	}
+
+	findCtx, cancel := context.WithTimeout(ctx, findTimeout)
+	defer cancel()
+
+	if opts.Trace {
+		report.Result, report.Trace, err = FindProvidersTraced(findCtx, c.disc, opts.Namespace, opts.OnProvider)
+	} else {
+		report.Result, err = FindProviders(findCtx, c.disc, opts.Namespace, opts.OnProvider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// waitRoutingTable bootstraps the client if needed and waits for its routing table (see WaitForRoutingTable)
+func (c *Client) waitRoutingTable(ctx context.Context, minSize int, timeout time.Duration) (int, time.Duration, bool, error) {
+	if _, err := c.Bootstrap(ctx); err != nil {
+		return 0, 0, false, err
+	}
+	rtSize, bootTime, ready := WaitForRoutingTable(ctx, c.dhtCli, minSize, timeout)
+	return rtSize, bootTime, ready, nil
+}
+
+// ProvideOptions tune a single advertisement. Zero values fall back to the provide defaults
+type ProvideOptions struct {
+	Namespace string
+	// TTL is the time the record is requested to live (the DHT default if zero)
+	TTL time.Duration
+	// MinRoutingTable is the number of peers the routing table needs before advertising
+	MinRoutingTable int
+	// BootstrapTimeout bounds the wait for the routing table, the namespace is advertised anyway once it expires
+	BootstrapTimeout time.Duration
+}
+
+// ProvideReport is the outcome of an advertisement
+type ProvideReport struct {
+	// TTL is the time the record lives according to the DHT
+	TTL  time.Duration
+	Took time.Duration
+	// RoutingTableSize is the size of the routing table when the advertisement started
+	RoutingTableSize int
+	// BootstrapTime is the time spent waiting for the routing table
+	BootstrapTime time.Duration
+	// Ready is false if the routing table didn't reach MinRoutingTable before the bootstrap timeout
+	Ready bool
+}
+
+// Provide bootstraps the client if needed, waits for its routing table and advertises the
+// client as a provider of the namespace. Re-advertising is left to the caller
+func (c *Client) Provide(ctx context.Context, opts ProvideOptions) (*ProvideReport, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("provide without namespace")
+	}
+	minRoutingTable := opts.MinRoutingTable
+	if minRoutingTable == 0 {
+		minRoutingTable = DefaultLookupMinRoutingTable
+	}
+	bootTimeout := opts.BootstrapTimeout
+	if bootTimeout == 0 {
+		bootTimeout = DefaultLookupBootTimeout
+	}
+	if bootTimeout < 0 || opts.TTL < 0 {
+		return nil, fmt.Errorf("bootstrap timeout and TTL can't be negative")
+	}
+
+	report := new(ProvideReport)
+	var err error
+	report.RoutingTableSize, report.BootstrapTime, report.Ready, err = c.waitRoutingTable(ctx, minRoutingTable, bootTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var discOpts []discovery.Option
+	if opts.TTL > 0 {
+		discOpts = append(discOpts, discovery.TTL(opts.TTL))
+	}
+	start := time.Now()
+	report.TTL, err = c.disc.Advertise(ctx, opts.Namespace, discOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("advertising %s: %w", opts.Namespace, err)
+	}
+	report.Took = time.Since(start)
+	return report, nil
+}
+
+// RecordHoldersOptions tune the search for the peers holding a provider record
+type RecordHoldersOptions struct {
+	Namespace string
+	Provider  peer.ID
+	// Interval is the time between attempts, which also bounds each of them
+	Interval time.Duration
+	// MinRoutingTable is the number of peers the routing table needs before the first attempt
+	MinRoutingTable int
+	// BootstrapTimeout bounds the wait for the routing table, the search starts anyway once it expires
+	BootstrapTimeout time.Duration
+}
+
+// WaitForRecordHolders bootstraps the client if needed, waits for its routing table and then asks the
+// peers closest to the namespace for the provider's record until any holds it (see WaitForRecordHolders).
+// It returns the holders and the number of attempts
+func (c *Client) WaitForRecordHolders(ctx context.Context, opts RecordHoldersOptions) ([]peer.ID, int, error) {
+	if opts.Namespace == "" || opts.Provider == "" {
+		return nil, 0, fmt.Errorf("record holders search without namespace or provider")
+	}
+	if opts.Interval <= 0 {
+		return nil, 0, fmt.Errorf("interval has to be greater than 0, got %s", opts.Interval)
+	}
+	minRoutingTable := opts.MinRoutingTable
+	if minRoutingTable == 0 {
+		minRoutingTable = DefaultLookupMinRoutingTable
+	}
+	bootTimeout := opts.BootstrapTimeout
+	if bootTimeout == 0 {
+		bootTimeout = DefaultLookupBootTimeout
+	}
+
+	if _, _, _, err := c.waitRoutingTable(ctx, minRoutingTable, bootTimeout); err != nil {
+		return nil, 0, err
+	}
+	pm, err := pb.NewProtocolMessenger(&MessageSender{H: c.h, Protocols: []protocol.ID{c.netConf.KadProtocol()}, Timeout: opts.Interval})
+	if err != nil {
+		return nil, 0, err
+	}
+	return WaitForRecordHolders(ctx, c.dhtCli, pm, opts.Namespace, opts.Provider, opts.Interval)
+}
+
+// CheckBootstrappers resolves and dials each of the given bootstrap entries from the client host,
+// bounding each step by the timeout. The entries default to the bootstrappers of the network and
+// their DNS addresses are resolved with the resolver given by WithResolver
+func (c *Client) CheckBootstrappers(ctx context.Context, entries []string, timeout time.Duration) ([]*BootstrapCheck, error) {
+	if len(entries) == 0 {
+		entries = c.netConf.Bootstrappers
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("network %s has no bootstrappers", c.netConf.Name)
+	}
+	return NewBootstrapChecker(c.h, c.resolver, c.netConf.KadProtocol(), timeout).Check(ctx, entries)
+}
@@ -1,16 +1,42 @@
 package dht
 
+import "time"
+
 // Root Config
 var (
 	DefaultLogLevel  = "info"
 	DefaultLogFormat = "text"
 
 	CustomUserAgent = "probelab-dht-crawler"
+
+	// DefaultIdentity used to be the legacy key shared by every deployment, which
+	// can still be selected with IdentityLegacy
+	DefaultIdentity = IdentityEphemeral
 )
 
 type RootConfig struct {
-	LogLevel  string
-	LogFormat string
+	LogLevel       string
+	LogFormat      string
+	NetworksConfig string
+}
+
+type KeygenCmdConfig struct {
+	Out   string
+	Force bool
+}
+
+// Bootstrap-check Config
+var (
+	DefaultBootCheckMinHealthy = 1
+	DefaultBootCheckTimeout    = 10 * time.Second
+)
+
+type BootCheckCmdConfig struct {
+	Network    string
+	Identity   string
+	Bootstrap  []string
+	MinHealthy int64
+	Timeout    time.Duration
 }
 
 // Lookup Config
@@ -18,11 +44,137 @@ var (
 	DefaultNetwork     = Mainnet
 	DefaultIsNamespace = false
 	DefaultNamespace   = NsFull
+
+	DefaultLookupMinRoutingTable = 10
+	DefaultLookupBootTimeout     = 10 * time.Second
+	DefaultLookupFindTimeout     = 15 * time.Second
+	DefaultLookupRepeat          = 1
+	DefaultLookupInterval        = 10 * time.Second
+	DefaultLookupFresh           = FreshNone
+	DefaultLookupTraceFormat     = TraceTree
+	DefaultLookupTraceOutPath    = "-"
 )
 
 type LookupCmdConfig struct {
-	Network string
+	Network   string
+	Identity  string
+	Bootstrap []string
+	SeedFile  string
+
+	IsCustomNamespace bool
+	Namespace         string
+
+	MinRoutingTable int64
+	BootTimeout     time.Duration
+	FindTimeout     time.Duration
+
+	Repeat   int64
+	Interval time.Duration
+	Fresh    string
+
+	Trace        bool
+	TraceFormat  string
+	TraceOutPath string
+}
+
+// Provide Config
+var (
+	DefaultProvideListen         = []string{"/ip4/0.0.0.0/tcp/0"}
+	DefaultProvideInterval       = time.Hour
+	DefaultProvideVerifyMode     = VerifyLookup
+	DefaultProvideVerifyTimeout  = 2 * time.Minute
+	DefaultProvideVerifyInterval = 5 * time.Second
+)
+
+type ProvideCmdConfig struct {
+	Network   string
+	Identity  string
+	Bootstrap []string
+	SeedFile  string
+	Listen    []string
 
 	IsCustomNamespace bool
 	Namespace         string
+
+	MinRoutingTable int64
+	BootTimeout     time.Duration
+	Interval        time.Duration
+	Once            bool
+
+	Verify         bool
+	VerifyMode     string
+	VerifyTimeout  time.Duration
+	VerifyInterval time.Duration
+}
+
+// Devnet Config
+var (
+	DefaultDevnetNodes     = 10
+	DefaultDevnetListen    = "/ip4/127.0.0.1/tcp/0"
+	DefaultDevnetProviders = []string{"full=3", "archival=2"}
+	DefaultDevnetOutPath   = ""
+)
+
+type DevnetCmdConfig struct {
+	Nodes     int64
+	Listen    string
+	Providers []string
+
+	IsCustomNamespace bool
+	OutPath           string
+}
+
+// Crawl Config
+var (
+	DefaultCrawlMode           = CrawlModeFull
+	DefaultCrawlParallelism    = 300
+	DefaultCrawlConnectTimeout = 10 * time.Second
+	DefaultCrawlMsgTimeout     = 10 * time.Second
+	DefaultCrawlProvTimeout    = 30 * time.Second
+	DefaultCrawlMaxPeers       = 0
+	DefaultCrawlTimeBudget     = time.Duration(0)
+	DefaultCrawlRetries        = 0
+	DefaultCrawlMinReplication = 3
+	DefaultCrawlProgress       = 5 * time.Second
+	DefaultCrawlOutput         = OutputText
+	DefaultCrawlOutPath        = "-"
+	DefaultCrawlGraphFormat    = GraphDOT
+	DefaultCrawlGraphOutPath   = ""
+)
+
+// DefaultCrawlNamespaces returns every known NodeType as namespace
+func DefaultCrawlNamespaces() []string {
+	namespaces := make([]string, len(NodeTypes))
+	for i, nodeType := range NodeTypes {
+		namespaces[i] = nodeType.String()
+	}
+	return namespaces
+}
+
+type CrawlCmdConfig struct {
+	Network   string
+	Identity  string
+	Bootstrap []string
+	SeedFile  string
+	Mode      string
+
+	IsCustomNamespace bool
+	Namespaces        []string
+
+	Parallelism    int64
+	ConnectTimeout time.Duration
+	MsgTimeout     time.Duration
+	ProvTimeout    time.Duration
+	MaxPeers       int64
+	TimeBudget     time.Duration
+	Retries        int64
+
+	MinReplication int64
+	Progress       time.Duration
+
+	Output  string
+	OutPath string
+
+	GraphFormat  string
+	GraphOutPath string
 }
@@ -2,6 +2,9 @@ package dht
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -12,85 +15,279 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
+// providerRetryBackoff is the wait before the first retry of a provider query, doubled on each further one
+const providerRetryBackoff = 100 * time.Millisecond
+
+type CrawlMode string
+
+func (m CrawlMode) String() string { return string(m) }
+
+const (
+	// CrawlModeFull visits every reachable peer in the network
+	CrawlModeFull CrawlMode = "full"
+	// CrawlModeNeighborhood only visits the peers closest to the crawled keys
+	CrawlModeNeighborhood CrawlMode = "neighborhood"
+)
+
+func CrawlModeFromString(mode string) (CrawlMode, error) {
+	switch strings.ToLower(mode) {
+	case CrawlModeFull.String():
+		return CrawlModeFull, nil
+	case CrawlModeNeighborhood.String():
+		return CrawlModeNeighborhood, nil
+	default:
+		return "", fmt.Errorf("unknown crawl mode: %q", mode)
+	}
+}
+
 // Crawler is simply a wrappper on top of the official go-lip2p-kad-dht/crawler
 // It is mainly used to crawl the network searching for Hydra-Booster peers.
 // with the final intention of blacklisting them in the Kad-Dht process if the Avoid-Hydras flags gets triggered
 type BaseCrawler struct {
+	// crawler is the official crawler, only created by the first full crawl
 	crawler *crawler.DefaultCrawler
 	h       host.Host
+	ptcls   []protocol.ID
 	pm      *pb.ProtocolMessenger
+	opts    *crawlerOptions
+	// results of the running crawl, replaced on each run
 	results *CrawlResults
 }
 
-func New(h host.Host, ptcls []protocol.ID, pm *pb.ProtocolMessenger) (*BaseCrawler, error) {
-	// create the official crawler
-	c, err := crawler.NewDefaultCrawler(
-		h,
-		crawler.WithParallelism(300),
-		crawler.WithMsgTimeout(10*time.Second),
-		crawler.WithConnectTimeout(10*time.Second),
-		crawler.WithProtocols(ptcls),
-	)
-	if err != nil {
+func New(h host.Host, ptcls []protocol.ID, pm *pb.ProtocolMessenger, opts ...CrawlerOption) (*BaseCrawler, error) {
+	o := new(crawlerOptions)
+	if err := defaultCrawlerOptions(o); err != nil {
 		return nil, err
 	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
 
 	return &BaseCrawler{
-		crawler: c,
-		h:       h,
-		pm:      pm,
-		results: NewCrawlerResults(),
+		h:     h,
+		ptcls: ptcls,
+		pm:    pm,
+		opts:  o,
 	}, nil
 }
 
-func (c *BaseCrawler) Run(ctx context.Context, startingNodes []*peer.AddrInfo, recordKey string) *CrawlResults {
-	recordCid, err := KeyToCid(recordKey)
+// defaultCrawler returns the official crawler, creating it on the first call
+func (c *BaseCrawler) defaultCrawler() (*crawler.DefaultCrawler, error) {
+	if c.crawler != nil {
+		return c.crawler, nil
+	}
+	dc, err := crawler.NewDefaultCrawler(
+		c.h,
+		crawler.WithParallelism(c.opts.parallelism),
+		crawler.WithMsgTimeout(c.opts.msgTimeout),
+		crawler.WithConnectTimeout(c.opts.connectTimeout),
+		crawler.WithProtocols(c.ptcls),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.crawler = dc
+	return dc, nil
+}
+
+// Run crawls the network from the starting nodes asking every visited peer for the providers of the
+// given keys. Each run returns its own results, and a BaseCrawler runs a single crawl at a time
+func (c *BaseCrawler) Run(ctx context.Context, startingNodes []*peer.AddrInfo, recordKeys []string) *CrawlResults {
+	recordCids := make([]cid.Cid, len(recordKeys))
+	for i, recordKey := range recordKeys {
+		recordCid, err := KeyToCid(recordKey)
+		if err != nil {
+			return nil
+		}
+		recordCids[i] = recordCid
+	}
+	dc, err := c.defaultCrawler()
 	if err != nil {
+		log.Errorf("creating the crawler: %s", err)
 		return nil
 	}
 
+	// only the given context interrupts or stops the crawl, the time budget and max peers complete it
+	parent := ctx
+
+	// limit the overall duration of the crawl if there is a time budget
+	if c.opts.timeBudget > 0 {
+		var budgetCancel context.CancelFunc
+		ctx, budgetCancel = context.WithTimeout(ctx, c.opts.timeBudget)
+		defer budgetCancel()
+	}
+	crawlCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// stop the crawl once we reach the max number of visited peers. The peer that reaches it
+	// is still handled, so the crawl is only stopped once its provider queries are done
+	var visited atomic.Int64
+	visit := func() (ok bool, last bool) {
+		if c.opts.maxPeers <= 0 {
+			return true, false
+		}
+		n := visited.Add(1)
+		return n <= int64(c.opts.maxPeers), n == int64(c.opts.maxPeers)
+	}
+
 	// set up the handle Success function for the crawler
+	found := newDiscoveries()
 	handleSucc := func(p peer.ID, rtPeers []*peer.AddrInfo) {
-		c.results.addSuccessfullPeer(p, peer.AddrInfo{})
-
-		// get the agent version from the peer
-		av := "unknown"
-		avIntf, err := c.h.Peerstore().Get(p, "AgentVersion")
-		if err == nil {
-			av = avIntf.(string)
+		ok, last := visit()
+		if !ok {
+			return
+		}
+		if last {
+			defer cancel()
 		}
-		c.results.addAgentVersion(av)
+		c.recordSuccess(found, p, rtPeers)
 
-		log.Tracef("peer: %s | agent_version: %s\n", p.String(), av)
+		// on each successfull connection, request the PRs from each of the keys
+		c.queryProviders(crawlCtx, p, recordKeys, recordCids)
+	}
 
-		// on each successfull connection, request the PRs from the key
-		provs, _, err := c.pm.GetProviders(ctx, p, recordCid.Hash())
-		if err != nil {
+	// set up the handle Fail function for the crawler
+	handleFail := func(p peer.ID, err error) {
+		ok, last := visit()
+		if !ok {
 			return
 		}
+		if last {
+			defer cancel()
+		}
+		c.recordFailure(p, err)
+	}
+
+	c.results = NewCrawlerResults()
+	c.results.start(c.h.ID(), recordKeys)
+	c.emit(CrawlStarted{Results: c.results})
+	c.discover(found, "", startingNodes)
+	dc.Run(crawlCtx, startingNodes, handleSucc, handleFail)
+	c.results.finish(crawlStatus(parent))
+	c.emit(CrawlFinished{Results: c.results, Duration: c.results.GetCrawlerDuration()})
+
+	return c.results
+}
+
+// recordSuccess adds a crawled peer and its routing table to the results, emitting
+// PeerConnected the first time and PeerDiscovered for each of its new neighbors
+func (c *BaseCrawler) recordSuccess(found *discoveries, p peer.ID, rtPeers []*peer.AddrInfo) {
+	info := c.peerInfo(p)
+	if c.results.addSuccessfullPeer(p, info) {
+		c.results.addAgentVersion(info.AgentVersion)
+		c.emit(PeerConnected{Info: info, Neighbors: len(rtPeers)})
+	}
+	c.results.addNeighbors(p, rtPeers)
+	c.discover(found, p, rtPeers)
+
+	log.Tracef("peer: %s | agent_version: %s | addrs: %v\n", p.String(), info.AgentVersion, info.Addrs)
+}
+
+// recordFailure adds a peer that couldn't be crawled to the results, emitting PeerFailed the first time
+func (c *BaseCrawler) recordFailure(p peer.ID, err error) {
+	// the crawler reports the failures of the dial and of the FIND_NODE requests alike,
+	// so the timeouts of a peer that is still connected come from the requests
+	if c.h.Network().Connectedness(p) == network.Connected {
+		err = asResponseTimeout(err)
+	}
+	failure := CrawlFailure{Category: ClassifyError(err)}
+	if err != nil {
+		failure.Error = err.Error()
+	}
+	info := c.peerInfo(p)
+	if c.results.addFailedPeer(p, info, failure) {
+		c.emit(PeerFailed{Info: info, Failure: failure})
+	}
+	log.Tracef("peer: %s | agent_version: unknonw | failure: %s | error: %s\n", p.String(), failure.Category, failure.Error)
+}
+
+// queryProviders requests the PRs of each of the keys to the remote peer and records them in the results
+func (c *BaseCrawler) queryProviders(ctx context.Context, p peer.ID, recordKeys []string, recordCids []cid.Cid) {
+	for i, recordKey := range recordKeys {
+		provs, query := c.getProviders(ctx, p, recordCids[i].Hash())
+		if query.Error != "" {
+			query.Namespace = recordKey
+			c.results.addQueryFailure(p, query)
+			if !query.Recovered {
+				log.Tracef("peer: %s | namespace: %s | attempts: %d | get-providers failed: %s\n", p.String(), recordKey, query.Attempts, query.Error)
+				continue
+			}
+		}
 		if len(provs) > 0 {
+			found := make([]peer.AddrInfo, 0, len(provs))
 			for _, provider := range provs {
-				c.results.addProvider(provider.ID, *provider)
+				c.results.addProvider(recordKey, p, provider.ID, *provider)
+				found = append(found, *provider)
 			}
+			c.emit(ProvidersFound{Namespace: recordKey, Holder: p, Providers: found})
 			log.Debugf("peer %s reported %d providers for %s nodes\n", p.String(), len(provs), recordKey)
 		}
 	}
+}
 
-	// set up the handle Fail function for the crawler
-	handleFail := func(p peer.ID, err error) {
-		c.results.addFailedPeer(p, peer.AddrInfo{})
-		log.Tracef("peer: %s | agent_version: unknonw | error: %s\n", p.String(), err.Error())
+// peerInfo composes the PeerInfo of the given peer out of the data in the host's peerstore
+func (c *BaseCrawler) peerInfo(p peer.ID) PeerInfo {
+	ps := c.h.Peerstore()
+	info := PeerInfo{
+		AddrInfo:     ps.PeerInfo(p),
+		AgentVersion: "unknown",
 	}
 
-	c.results.initTime = time.Now()
-	c.crawler.Run(ctx, startingNodes, handleSucc, handleFail)
-	c.results.finishTime = time.Now()
+	if av, err := ps.Get(p, "AgentVersion"); err == nil {
+		if s, ok := av.(string); ok && s != "" {
+			info.AgentVersion = s
+		}
+	}
+	if pv, err := ps.Get(p, "ProtocolVersion"); err == nil {
+		if s, ok := pv.(string); ok {
+			info.ProtocolVersion = s
+		}
+	}
+	if prots, err := ps.GetProtocols(p); err == nil {
+		info.Protocols = prots
+	}
+	return info
+}
 
-	return c.results
+// getProviders requests the PRs for the given key to the remote peer, retrying up to the configured times
+// with a growing backoff. Along with the providers, it returns the outcome of the query, which only
+// carries an Error if any of the attempts failed
+func (c *BaseCrawler) getProviders(ctx context.Context, p peer.ID, key mh.Multihash) ([]*peer.AddrInfo, ProviderQueryFailure) {
+	var query ProviderQueryFailure
+	start := time.Now()
+	for attempt := 0; attempt <= c.opts.retries; attempt++ {
+		if attempt > 0 {
+			backoff := time.NewTimer(providerRetryBackoff << (attempt - 1))
+			select {
+			case <-backoff.C:
+			case <-ctx.Done():
+				backoff.Stop()
+				query.Latency = time.Since(start)
+				return nil, query
+			}
+		}
+		query.Attempts++
+		provs, _, err := c.pm.GetProviders(ctx, p, key)
+		if err == nil {
+			query.Latency = time.Since(start)
+			query.Recovered = query.Error != ""
+			return provs, query
+		}
+		query.Error = err.Error()
+		if ctx.Err() != nil {
+			break
+		}
+		log.Tracef("peer: %s | attempt: %d | get-providers error: %s\n", p.String(), attempt+1, err.Error())
+	}
+	query.Latency = time.Since(start)
+	return nil, query
 }
 
 func (c *BaseCrawler) Close() {
@@ -0,0 +1,82 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// flakySender fails the first given number of requests, then answers them without providers
+type flakySender struct {
+	failures int
+	requests int
+}
+
+func (s *flakySender) SendRequest(ctx context.Context, _ peer.ID, pmes *pb.Message) (*pb.Message, error) {
+	s.requests++
+	if s.requests <= s.failures {
+		return nil, errors.New("stream reset")
+	}
+	return pb.NewMessage(pmes.GetType(), pmes.GetKey(), 0), nil
+}
+
+func (s *flakySender) SendMessage(context.Context, peer.ID, *pb.Message) error { return nil }
+
+func TestCrawlerGetProvidersRetries(t *testing.T) {
+	key, err := KeyToCid(NsFull.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		failures int
+		retries  int
+		// budget bounds the query, if not zero
+		budget    time.Duration
+		attempts  int
+		failed    bool
+		recovered bool
+		// wait is the least time the retries are expected to take
+		wait time.Duration
+	}{
+		{name: "first attempt", failures: 0, retries: 2, attempts: 1},
+		{name: "no retries", failures: 1, retries: 0, attempts: 1, failed: true},
+		{name: "recovered", failures: 2, retries: 2, attempts: 3, recovered: true, wait: 3 * providerRetryBackoff},
+		{name: "exhausted", failures: 3, retries: 2, attempts: 3, failed: true, wait: 3 * providerRetryBackoff},
+		// the second retry would only start after the context is done
+		{name: "cancelled backoff", failures: 5, retries: 4, budget: 2 * providerRetryBackoff, attempts: 2, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &flakySender{failures: tt.failures}
+			pm, err := pb.NewProtocolMessenger(sender)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &BaseCrawler{pm: pm, opts: &crawlerOptions{retries: tt.retries}}
+
+			ctx := context.Background()
+			if tt.budget > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.budget)
+				defer cancel()
+			}
+			_, query := c.getProviders(ctx, "peer", key.Hash())
+
+			if query.Attempts != tt.attempts || sender.requests != tt.attempts {
+				t.Errorf("expected %d attempts, got %d (%d requests)", tt.attempts, query.Attempts, sender.requests)
+			}
+			if failed := query.Error != "" && !query.Recovered; failed != tt.failed || query.Recovered != tt.recovered {
+				t.Errorf("expected failed=%t and recovered=%t, got %+v", tt.failed, tt.recovered, query)
+			}
+			if query.Latency < tt.wait {
+				t.Errorf("expected the retries to back off for at least %s, took %s", tt.wait, query.Latency)
+			}
+		})
+	}
+}
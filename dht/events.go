@@ -0,0 +1,110 @@
+package dht
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// CrawlEvent is emitted by the BaseCrawler while a crawl runs. It is one of
+// CrawlStarted, PeerDiscovered, PeerConnected, PeerFailed, ProvidersFound or CrawlFinished
+type CrawlEvent interface {
+	crawlEvent()
+}
+
+// CrawlStarted is the first event of a crawl. Results are filled up as the crawl
+// runs, so they can be snapshotted before it finishes
+type CrawlStarted struct {
+	Results *CrawlResults
+}
+
+// PeerDiscovered is emitted the first time the crawl learns about a peer
+type PeerDiscovered struct {
+	Peer peer.ID
+	// From is the crawled peer that returned it, empty for the starting nodes
+	From peer.ID
+}
+
+// PeerConnected is emitted once a peer was crawled successfully
+type PeerConnected struct {
+	Info PeerInfo
+	// Neighbors is the number of peers it returned
+	Neighbors int
+}
+
+// PeerFailed is emitted once a peer couldn't be crawled
+type PeerFailed struct {
+	Info    PeerInfo
+	Failure CrawlFailure
+}
+
+// ProvidersFound is emitted each time a crawled peer returns providers for a namespace
+type ProvidersFound struct {
+	Namespace string
+	Holder    peer.ID
+	Providers []peer.AddrInfo
+}
+
+// CrawlFinished is the last event of a crawl
+type CrawlFinished struct {
+	Results  *CrawlResults
+	Duration time.Duration
+}
+
+func (CrawlStarted) crawlEvent()   {}
+func (PeerDiscovered) crawlEvent() {}
+func (PeerConnected) crawlEvent()  {}
+func (PeerFailed) crawlEvent()     {}
+func (ProvidersFound) crawlEvent() {}
+func (CrawlFinished) crawlEvent()  {}
+
+// CrawlObserver receives the events of a crawl. OnCrawlEvent is called from the
+// crawler workers, so it has to be safe for concurrent use and it shouldn't block.
+// The crawl can be stopped early by cancelling its context, which marks it as CrawlStatusStopped
+type CrawlObserver interface {
+	OnCrawlEvent(CrawlEvent)
+}
+
+// CrawlObserverFunc adapts a function to a CrawlObserver
+type CrawlObserverFunc func(CrawlEvent)
+
+func (f CrawlObserverFunc) OnCrawlEvent(e CrawlEvent) { f(e) }
+
+// emit hands the event to every observer of the crawler
+func (c *BaseCrawler) emit(e CrawlEvent) {
+	for _, obs := range c.opts.observers {
+		obs.OnCrawlEvent(e)
+	}
+}
+
+// discoveries keeps track of the peers already announced with PeerDiscovered
+type discoveries struct {
+	m    sync.Mutex
+	seen map[peer.ID]struct{}
+}
+
+func newDiscoveries() *discoveries {
+	return &discoveries{seen: make(map[peer.ID]struct{})}
+}
+
+// add returns true if the peer wasn't seen before
+func (d *discoveries) add(p peer.ID) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if _, ok := d.seen[p]; ok {
+		return false
+	}
+	d.seen[p] = struct{}{}
+	return true
+}
+
+// discover emits PeerDiscovered for the peers that weren't seen before, skipping the crawler itself
+func (c *BaseCrawler) discover(d *discoveries, from peer.ID, peers []*peer.AddrInfo) {
+	for _, ai := range peers {
+		if ai.ID != c.h.ID() && d.add(ai.ID) {
+			c.emit(PeerDiscovered{Peer: ai.ID, From: from})
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package dht
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// eventRecorder keeps every event it observes, in order
+type eventRecorder struct {
+	m      sync.Mutex
+	events []CrawlEvent
+}
+
+func (r *eventRecorder) OnCrawlEvent(e CrawlEvent) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) recorded() []CrawlEvent {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]CrawlEvent{}, r.events...)
+}
+
+func TestCrawlerRunEvents(t *testing.T) {
+	topo := newMockTopology(t, 8, starShape)
+	topo.unreachable(3)
+	full := NsFull.String()
+	topo.place(full, 1, 2, 4)
+
+	rec := new(eventRecorder)
+	results := topo.crawl([]string{full}, WithObserver(rec))
+
+	var (
+		discovered = make(map[peer.ID]int)
+		connected  = make(map[peer.ID]int)
+		failed     = make(map[peer.ID]int)
+		holders    = make(map[peer.ID]int)
+		started    int
+		finished   int
+	)
+	events := rec.recorded()
+	for i, e := range events {
+		switch e := e.(type) {
+		case CrawlStarted:
+			started++
+			if i != 0 || e.Results != results {
+				t.Errorf("expected CrawlStarted to be the first event with the results, got event %d: %+v", i+1, e)
+			}
+		case PeerDiscovered:
+			discovered[e.Peer]++
+			if e.Peer == topo.id(0) && e.From != "" {
+				t.Errorf("expected the starting node to be discovered from nobody, got %s", e.From)
+			}
+		case PeerConnected:
+			connected[e.Info.ID]++
+			if discovered[e.Info.ID] == 0 {
+				t.Errorf("peer %s connected before being discovered", e.Info.ID)
+			}
+		case PeerFailed:
+			failed[e.Info.ID]++
+			if e.Failure.Error == "" {
+				t.Errorf("expected an error for the failed peer %s", e.Info.ID)
+			}
+		case ProvidersFound:
+			holders[e.Holder]++
+			if e.Namespace != full || len(e.Providers) != 1 || e.Providers[0].ID != topo.id(1) {
+				t.Errorf("unexpected providers event %+v", e)
+			}
+		case CrawlFinished:
+			finished++
+			if i != len(events)-1 {
+				t.Errorf("CrawlFinished is event %d of %d", i+1, len(events))
+			}
+			if e.Results != results || e.Duration != results.GetCrawlerDuration() {
+				t.Errorf("unexpected CrawlFinished event %+v", e)
+			}
+		default:
+			t.Errorf("unexpected event %T", e)
+		}
+	}
+
+	assertPeerSet(t, "discovered peers", topo.ids(0, 1, 2, 3, 4, 5, 6, 7), discovered)
+	assertPeerSet(t, "connected peers", topo.ids(0, 1, 2, 4, 5, 6, 7), connected)
+	assertPeerSet(t, "failed peers", topo.ids(3), failed)
+	assertPeerSet(t, "holders", topo.ids(2, 4), holders)
+	for _, counts := range []map[peer.ID]int{discovered, connected, failed, holders} {
+		for p, n := range counts {
+			if n != 1 {
+				t.Errorf("expected a single event for %s, got %d", p, n)
+			}
+		}
+	}
+	if started != 1 || finished != 1 {
+		t.Errorf("expected a single CrawlStarted and CrawlFinished event, got %d and %d", started, finished)
+	}
+	if status := results.GetStatus(); status != CrawlStatusComplete {
+		t.Errorf("expected a complete crawl, got %s", status)
+	}
+}
+
+func TestCrawlerRunStopsOnceProvidersAreFound(t *testing.T) {
+	topo := newMockTopology(t, 12, ringShape)
+	full := NsFull.String()
+	topo.place(full, 5, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rec := new(eventRecorder)
+	stop := CrawlObserverFunc(func(e CrawlEvent) {
+		rec.OnCrawlEvent(e)
+		if _, ok := e.(ProvidersFound); ok {
+			cancel()
+		}
+	})
+	results := topo.crawlContext(ctx, []string{full}, WithObserver(stop))
+
+	assertPeerSet(t, "providers", topo.ids(5), results.GetProvPeers(full))
+	if crawled := len(results.GetSuccPeers()); crawled >= len(topo.nodes) {
+		t.Errorf("expected the crawl to stop early, crawled %d of %d peers", crawled, len(topo.nodes))
+	}
+	// stopping on purpose isn't an interruption
+	if status := results.GetStatus(); status != CrawlStatusStopped {
+		t.Errorf("expected a stopped crawl, got %s", status)
+	}
+	events := rec.recorded()
+	if _, ok := events[len(events)-1].(CrawlFinished); !ok {
+		t.Errorf("expected the stopped crawl to finish with CrawlFinished, got %T", events[len(events)-1])
+	}
+}
+
+func TestCrawlerRunInterrupted(t *testing.T) {
+	topo := newMockTopology(t, 12, ringShape)
+	full := NsFull.String()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	interrupt := CrawlObserverFunc(func(e CrawlEvent) {
+		if _, ok := e.(PeerConnected); ok {
+			cancel(ErrCrawlInterrupted)
+		}
+	})
+	results := topo.crawlContext(ctx, []string{full}, WithObserver(interrupt))
+
+	if status := results.GetStatus(); status != CrawlStatusInterrupted {
+		t.Errorf("expected an interrupted crawl, got %s", status)
+	}
+}
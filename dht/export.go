@@ -0,0 +1,215 @@
+package dht
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type OutputFormat string
+
+func (f OutputFormat) String() string { return string(f) }
+
+const (
+	// OutputText keeps the human readable log summary only
+	OutputText OutputFormat = "text"
+	// OutputJSON writes the whole CrawlSnapshot as a single JSON document
+	OutputJSON OutputFormat = "json"
+	// OutputNDJSON writes one NDJSONRecord per line
+	OutputNDJSON OutputFormat = "ndjson"
+	// OutputCSV writes one row per peer and provider (see CSVHeader)
+	OutputCSV OutputFormat = "csv"
+)
+
+func OutputFormatFromString(format string) (OutputFormat, error) {
+	switch strings.ToLower(format) {
+	case OutputText.String():
+		return OutputText, nil
+	case OutputJSON.String():
+		return OutputJSON, nil
+	case OutputNDJSON.String():
+		return OutputNDJSON, nil
+	case OutputCSV.String():
+		return OutputCSV, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %q", format)
+	}
+}
+
+// NDJSON record types used in NDJSONRecord.Type
+const (
+	RecordTypeMetadata     = "metadata"
+	RecordTypePeer         = "peer"
+	RecordTypeProvider     = "provider"
+	RecordTypeAgentVersion = "agent_version"
+	RecordTypeFailure      = "failure_category"
+	RecordTypePlacement    = "placement"
+)
+
+// NDJSONRecord is the envelope of each line of the NDJSON output.
+// The first line is always the "metadata" record, followed by the "peer",
+// "provider", "agent_version", "failure_category" and "placement" records.
+// The Data of the "placement" records is a PlacementRecord.
+type NDJSONRecord struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	Data    any    `json:"data"`
+}
+
+// AgentVersionRecord is the Data of the "agent_version" NDJSON records
+type AgentVersionRecord struct {
+	AgentVersion string `json:"agent_version"`
+	Peers        int    `json:"peers"`
+}
+
+// FailureCategoryRecord is the Data of the "failure_category" NDJSON records
+type FailureCategoryRecord struct {
+	FailureCategory string `json:"failure_category"`
+	Peers           int    `json:"peers"`
+}
+
+// CSVHeader are the columns of the CSV output. The "kind" column is either
+// "peer" or "provider", the "namespace" column is only set for providers,
+// "crawl_status" repeats the CrawlStatus of the snapshot on every row,
+// and list values are separated by ";".
+var CSVHeader = []string{
+	"version",
+	"kind",
+	"namespace",
+	"peer_id",
+	"status",
+	"agent_version",
+	"protocol_version",
+	"addrs",
+	"protocols",
+	"failure_category",
+	"error",
+	"failed_provider_queries",
+	"replication_factor",
+	"crawl_status",
+}
+
+// Write serializes the snapshot into the given writer using the given format
+func (s *CrawlSnapshot) Write(w io.Writer, format OutputFormat) error {
+	switch format {
+	case OutputJSON:
+		return s.writeJSON(w)
+	case OutputNDJSON:
+		return s.writeNDJSON(w)
+	case OutputCSV:
+		return s.writeCSV(w)
+	default:
+		return fmt.Errorf("output format %q can't be used to export the crawl results", format)
+	}
+}
+
+func (s *CrawlSnapshot) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func (s *CrawlSnapshot) writeNDJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	write := func(recordType string, data any) error {
+		return enc.Encode(NDJSONRecord{Version: s.Version, Type: recordType, Data: data})
+	}
+
+	if err := write(RecordTypeMetadata, s.Metadata); err != nil {
+		return err
+	}
+	for _, p := range s.Peers {
+		if err := write(RecordTypePeer, p); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.Providers {
+		if err := write(RecordTypeProvider, p); err != nil {
+			return err
+		}
+	}
+	for _, av := range sortedKeys(s.AgentVersions) {
+		if err := write(RecordTypeAgentVersion, AgentVersionRecord{AgentVersion: av, Peers: s.AgentVersions[av]}); err != nil {
+			return err
+		}
+	}
+	for _, category := range sortedKeys(s.FailureCategories) {
+		if err := write(RecordTypeFailure, FailureCategoryRecord{FailureCategory: category, Peers: s.FailureCategories[category]}); err != nil {
+			return err
+		}
+	}
+	for _, placement := range s.Placement {
+		if err := write(RecordTypePlacement, placement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CrawlSnapshot) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	version := strconv.Itoa(s.Version)
+	for _, p := range s.Peers {
+		row := []string{
+			version,
+			RecordTypePeer,
+			"",
+			p.PeerID,
+			p.Status,
+			p.AgentVersion,
+			p.ProtocolVersion,
+			strings.Join(p.Addrs, ";"),
+			strings.Join(p.Protocols, ";"),
+			p.FailureCategory,
+			p.Error,
+			strings.Join(failedQueryNamespaces(p), ";"),
+			"",
+			s.Metadata.Status,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.Providers {
+		row := []string{
+			version,
+			RecordTypeProvider,
+			p.Namespace,
+			p.PeerID,
+			"",
+			"",
+			"",
+			strings.Join(p.Addrs, ";"),
+			"",
+			"",
+			"",
+			"",
+			strconv.Itoa(p.ReplicationFactor),
+			s.Metadata.Status,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// failedQueryNamespaces returns the namespaces whose provider query didn't recover for the given peer
+func failedQueryNamespaces(p PeerRecord) []string {
+	namespaces := make([]string, 0)
+	for _, query := range p.ProviderQueryFailures {
+		if !query.Recovered {
+			namespaces = append(namespaces, query.Namespace)
+		}
+	}
+	return namespaces
+}
@@ -0,0 +1,119 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/multiformats/go-multistream"
+)
+
+// FailureCategory classifies why a peer couldn't be crawled
+type FailureCategory string
+
+func (f FailureCategory) String() string { return string(f) }
+
+const (
+	FailureDialTimeout          FailureCategory = "dial_timeout"
+	FailureResponseTimeout      FailureCategory = "response_timeout"
+	FailureConnectionRefused    FailureCategory = "connection_refused"
+	FailureNoAddresses          FailureCategory = "no_addresses"
+	FailureProtocolNotSupported FailureCategory = "protocol_not_supported"
+	FailureResourceManager      FailureCategory = "resource_manager"
+	FailureSecurityHandshake    FailureCategory = "security_handshake"
+	FailureStreamReset          FailureCategory = "stream_reset"
+	FailureCanceled             FailureCategory = "canceled"
+	FailureUnknown              FailureCategory = "unknown"
+)
+
+// ErrResponseTimeout wraps the timeouts of a peer that accepted the connection but didn't answer in time
+var ErrResponseTimeout = errors.New("response timeout")
+
+// CrawlFailure holds the classified error of a peer that couldn't be crawled
+type CrawlFailure struct {
+	Category FailureCategory
+	Error    string
+}
+
+// ProviderQueryFailure holds the outcome of a GetProviders query that failed
+// at least once against a peer we could connect to
+type ProviderQueryFailure struct {
+	Namespace string
+	// Error is the last error returned by the remote peer
+	Error string
+	// Latency is the total time spent on the query, including the retries
+	Latency  time.Duration
+	Attempts int
+	// Recovered is true if one of the retries succeeded
+	Recovered bool
+}
+
+// ClassifyError maps the error returned by the crawler for a peer into a FailureCategory.
+// Typed errors are checked first, falling back to the error message for the
+// errors that libp2p's transports don't expose as types. Timeouts are dial
+// timeouts unless they are wrapped in ErrResponseTimeout.
+func ClassifyError(err error) FailureCategory {
+	if err == nil {
+		return FailureUnknown
+	}
+
+	switch {
+	case errors.Is(err, ErrResponseTimeout):
+		return FailureResponseTimeout
+	case errors.Is(err, swarm.ErrNoAddresses), errors.Is(err, swarm.ErrNoGoodAddresses):
+		return FailureNoAddresses
+	case errors.Is(err, multistream.ErrNotSupported[protocol.ID]{}):
+		return FailureProtocolNotSupported
+	case errors.Is(err, network.ErrResourceLimitExceeded), errors.Is(err, network.ErrResourceScopeClosed):
+		return FailureResourceManager
+	case errors.Is(err, network.ErrReset):
+		return FailureStreamReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return FailureConnectionRefused
+	case errors.Is(err, context.Canceled):
+		return FailureCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return FailureDialTimeout
+	}
+
+	var dialErr *swarm.DialError
+	if errors.As(err, &dialErr) && dialErr.Timeout() {
+		return FailureDialTimeout
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "no addresses"), strings.Contains(msg, "no good addresses"):
+		return FailureNoAddresses
+	case strings.Contains(msg, "protocols not supported"), strings.Contains(msg, "protocol not supported"):
+		return FailureProtocolNotSupported
+	case strings.Contains(msg, "resource limit exceeded"), strings.Contains(msg, "resource scope closed"):
+		return FailureResourceManager
+	case strings.Contains(msg, "security"), strings.Contains(msg, "handshake"), strings.Contains(msg, "peer id mismatch"):
+		return FailureSecurityHandshake
+	case strings.Contains(msg, "stream reset"):
+		return FailureStreamReset
+	case strings.Contains(msg, "connection refused"):
+		return FailureConnectionRefused
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+		return FailureDialTimeout
+	default:
+		return FailureUnknown
+	}
+}
+
+// asResponseTimeout wraps the timeouts of a peer we are connected to in ErrResponseTimeout,
+// leaving any other error as it is
+func asResponseTimeout(err error) error {
+	if err == nil || ClassifyError(err) != FailureDialTimeout {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrResponseTimeout, err)
+}
@@ -0,0 +1,62 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multistream"
+)
+
+func TestClassifyError(t *testing.T) {
+	addr := ma.StringCast("/ip4/127.0.0.1/tcp/4001")
+	dialErr := func(cause error) error {
+		return &swarm.DialError{
+			Peer:       "peer",
+			DialErrors: []swarm.TransportError{{Address: addr, Cause: cause}},
+		}
+	}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+
+	tests := []struct {
+		name string
+		err  error
+		want FailureCategory
+	}{
+		{"dial timeout", dialErr(fmt.Errorf("dial tcp: %w", os.ErrDeadlineExceeded)), FailureDialTimeout},
+		{"dial timeout message", errors.New("failed to dial: i/o timeout"), FailureDialTimeout},
+		{"connection refused", fmt.Errorf("crawling peer: %w", dialErr(refused)), FailureConnectionRefused},
+		{"connection refused message", errors.New("dial tcp 127.0.0.1:4001: connect: connection refused"), FailureConnectionRefused},
+		{"protocol not supported", fmt.Errorf("new stream: %w", multistream.ErrNotSupported[protocol.ID]{Protos: []protocol.ID{"/celestia/private/kad/1.0.0"}}), FailureProtocolNotSupported},
+		{"protocol not supported message", errors.New("failed to negotiate protocol: protocols not supported: [/celestia/private/kad/1.0.0]"), FailureProtocolNotSupported},
+		{"stream reset", fmt.Errorf("reading message: %w", network.ErrReset), FailureStreamReset},
+		{"stream reset message", errors.New("stream reset"), FailureStreamReset},
+		{"context deadline", fmt.Errorf("find node: %w", context.DeadlineExceeded), FailureDialTimeout},
+		{"context canceled", fmt.Errorf("find node: %w", context.Canceled), FailureCanceled},
+		{"response timeout", fmt.Errorf("find node: %w", asResponseTimeout(context.DeadlineExceeded)), FailureResponseTimeout},
+		{"response timeout message", asResponseTimeout(errors.New("read: i/o timeout")), FailureResponseTimeout},
+		{"canceled response", asResponseTimeout(context.Canceled), FailureCanceled},
+		{"reset response", asResponseTimeout(network.ErrReset), FailureStreamReset},
+		{"no addresses", fmt.Errorf("dial: %w", swarm.ErrNoAddresses), FailureNoAddresses},
+		{"no good addresses", fmt.Errorf("dial: %w", swarm.ErrNoGoodAddresses), FailureNoAddresses},
+		{"resource manager", fmt.Errorf("new stream: %w", network.ErrResourceLimitExceeded), FailureResourceManager},
+		{"security handshake", errors.New("failed to negotiate security protocol: peer id mismatch"), FailureSecurityHandshake},
+		{"unknown", errors.New("something else"), FailureUnknown},
+		{"nil", nil, FailureUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("expected %s for %v, got %s", tt.want, tt.err, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package dht
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type GraphFormat string
+
+func (f GraphFormat) String() string { return string(f) }
+
+const (
+	GraphDOT     GraphFormat = "dot"
+	GraphGraphML GraphFormat = "graphml"
+)
+
+func GraphFormatFromString(format string) (GraphFormat, error) {
+	switch strings.ToLower(format) {
+	case GraphDOT.String():
+		return GraphDOT, nil
+	case GraphGraphML.String():
+		return GraphGraphML, nil
+	default:
+		return "", fmt.Errorf("unknown graph format: %q", format)
+	}
+}
+
+// Node status used in GraphNode.Status for peers that were discovered but never visited
+const PeerStatusUnvisited = "unvisited"
+
+// CrawlGraph is the directed graph of the crawled network, where each edge
+// goes from a peer to one of the entries of its routing table
+type CrawlGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+type GraphNode struct {
+	PeerID       string
+	Status       string
+	AgentVersion string
+}
+
+type GraphEdge struct {
+	From string
+	To   string
+}
+
+// Graph composes the routing table graph out of the current state of the results
+func (r *CrawlResults) Graph() *CrawlGraph {
+	succPeers := r.GetSuccPeers()
+	failedPeers := r.GetFailedPeers()
+	neighbors := r.GetNeighbors()
+
+	nodes := make(map[peer.ID]GraphNode)
+	for p, info := range succPeers {
+		nodes[p] = GraphNode{PeerID: p.String(), Status: PeerStatusSuccess, AgentVersion: info.AgentVersion}
+	}
+	for p := range failedPeers {
+		nodes[p] = GraphNode{PeerID: p.String(), Status: PeerStatusFailed}
+	}
+
+	g := &CrawlGraph{}
+	for from, tos := range neighbors {
+		for _, to := range tos {
+			if _, ok := nodes[to]; !ok {
+				nodes[to] = GraphNode{PeerID: to.String(), Status: PeerStatusUnvisited}
+			}
+			g.Edges = append(g.Edges, GraphEdge{From: from.String(), To: to.String()})
+		}
+	}
+	for _, node := range nodes {
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].PeerID < g.Nodes[j].PeerID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// Write serializes the graph into the given writer using the given format
+func (g *CrawlGraph) Write(w io.Writer, format GraphFormat) error {
+	switch format {
+	case GraphDOT:
+		return g.writeDOT(w)
+	case GraphGraphML:
+		return g.writeGraphML(w)
+	default:
+		return fmt.Errorf("unknown graph format: %q", format)
+	}
+}
+
+func (g *CrawlGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph celestia_dht {\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "  %q [status=%q, agent_version=%q];\n", node.PeerID, node.Status, node.AgentVersion)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (g *CrawlGraph) writeGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "status", For: "node", AttrName: "status", AttrType: "string"},
+			{ID: "agent_version", For: "node", AttrName: "agent_version", AttrType: "string"},
+		},
+		Graph: graphMLGraph{
+			ID:          "celestia_dht",
+			EdgeDefault: "directed",
+		},
+	}
+	for _, node := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.PeerID,
+			Data: []graphMLData{
+				{Key: "status", Value: node.Status},
+				{Key: "agent_version", Value: node.AgentVersion},
+			},
+		})
+	}
+	for _, edge := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: edge.From, Target: edge.To})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
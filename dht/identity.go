@@ -0,0 +1,91 @@
+package dht
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// IdentityEphemeral generates a new random identity on each run
+	IdentityEphemeral = "ephemeral"
+	// IdentityLegacy uses the key embedded in the source (see LoadPrivKey).
+	// It is shared by every cnames deployment, so it should only be used to
+	// keep the peer ID that other tools already know
+	IdentityLegacy = "legacy"
+)
+
+// LoadIdentity returns the private key for the given identity, which is
+// either "ephemeral", "legacy" or the path to a key file created with GenerateIdentity
+func LoadIdentity(identity string) (crypto.PrivKey, error) {
+	switch identity {
+	case IdentityEphemeral, "":
+		privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		return privKey, err
+	case IdentityLegacy:
+		return LoadPrivKey(), nil
+	default:
+		raw, err := os.ReadFile(identity)
+		if err != nil {
+			return nil, fmt.Errorf("reading identity file: %w", err)
+		}
+		privKey, err := crypto.UnmarshalPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing identity file %s: %w", identity, err)
+		}
+		return privKey, nil
+	}
+}
+
+// GenerateIdentity creates a new Ed25519 key and stores it in the given path
+// using the libp2p protobuf encoding, readable only by its owner. It won't
+// overwrite an existing file unless force is set
+func GenerateIdentity(path string, force bool) (peer.ID, error) {
+	privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	raw, err := crypto.MarshalPrivateKey(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	// an existing file keeps its permissions when written, so it's replaced instead
+	if force {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("removing identity file: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("identity file %s already exists", path)
+	} else if err != nil {
+		return "", fmt.Errorf("creating identity file: %w", err)
+	}
+	if _, err := f.Write(raw); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("writing identity file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("writing identity file: %w", err)
+	}
+	return peer.IDFromPrivateKey(privKey)
+}
+
+// LoadPrivKey returns the legacy identity embedded in the source
+func LoadPrivKey() crypto.PrivKey {
+	// PeerID: 12D3KooWKyn4pnn6EZZVaxcexvCi7fgcfuvBnjnKXmcgnb8ptyGA
+	// nebula peer_id: 864
+	marshalledKey := []byte{231, 145, 17, 9, 50, 49, 35, 142, 193, 101, 216, 144, 202, 90, 29, 9, 115, 217, 85, 158, 241, 234, 36, 101, 128, 123, 72, 185, 107, 203, 43, 134, 150, 254, 30, 55, 24, 165, 176, 158, 88, 219, 161, 48, 38, 169, 151, 44, 171, 88, 212, 86, 158, 192, 33, 242, 10, 88, 197, 78, 233, 225, 88, 151}
+
+	privKey, err := crypto.UnmarshalEd25519PrivateKey(marshalledKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return privKey
+}
@@ -0,0 +1,57 @@
+package dht
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestGenerateIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cnames.key")
+
+	id, err := GenerateIdentity(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIdentityFile(t, path, id)
+
+	if _, err := GenerateIdentity(path, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected an existing identity file to be kept, got %v", err)
+	}
+
+	// a forced key replaces a world-readable file with an owner-only one
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	forced, err := GenerateIdentity(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forced == id {
+		t.Error("expected a new identity to be generated")
+	}
+	assertIdentityFile(t, path, forced)
+}
+
+// assertIdentityFile checks that the key file is only readable by its owner and holds the given identity
+func assertIdentityFile(t *testing.T, path string, id peer.ID) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected the identity file to have the permissions 0600, got %o", perm)
+	}
+
+	privKey, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := peer.IDFromPrivateKey(privKey); err != nil || loaded != id {
+		t.Errorf("expected the identity %s, got %s (%v)", id, loaded, err)
+	}
+}
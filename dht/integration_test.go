@@ -0,0 +1,459 @@
+package dht_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	kad "github.com/libp2p/go-libp2p-kad-dht"
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	routingdisc "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+
+	"github.com/probe-lab/celestia-dht-scripts/devnet"
+	"github.com/probe-lab/celestia-dht-scripts/dht"
+)
+
+const (
+	devnetNodes = 8
+	testTimeout = 30 * time.Second
+)
+
+// startDevnet runs a devnet with 3 providers of the full namespace and 1 of the archival one
+func startDevnet(t *testing.T) *devnet.Devnet {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping devnet integration test in short mode")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	d, err := devnet.New(ctx,
+		devnet.WithNodes(devnetNodes),
+		devnet.WithProviders(dht.NsFull.String(), 3),
+		devnet.WithProviders(dht.NsArchival.String(), 1),
+	)
+	if err != nil {
+		t.Fatalf("starting the devnet: %v", err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.NoListenAddrs, libp2p.DisableRelay())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func newCrawler(t *testing.T, h host.Host, opts ...dht.CrawlerOption) *dht.BaseCrawler {
+	t.Helper()
+	prots := []protocol.ID{devnet.Network.KadProtocol()}
+	pm, err := pb.NewProtocolMessenger(&dht.MessageSender{H: h, Protocols: prots, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]dht.CrawlerOption{dht.WithParallelism(10), dht.WithConnectTimeout(5 * time.Second)}, opts...)
+	crawler, err := dht.New(h, prots, pm, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crawler
+}
+
+// newClient joins the devnet with a DHT client on a new host
+func newClient(ctx context.Context, t *testing.T, d *devnet.Devnet) *kad.IpfsDHT {
+	t.Helper()
+	h := newHost(t)
+	dhtCli, err := kad.New(ctx, h,
+		kad.Mode(kad.ModeClient),
+		kad.BootstrapPeers(d.BootstrapPeers()...),
+		kad.ProtocolPrefix(devnet.Network.KadPrefix()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = dhtCli.Close() })
+
+	for _, ai := range d.BootstrapPeers() {
+		if err := h.Connect(ctx, ai); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, ready := dht.WaitForRoutingTable(ctx, dhtCli, devnetNodes, 10*time.Second); !ready {
+		t.Fatalf("routing table didn't reach %d peers", devnetNodes)
+	}
+	return dhtCli
+}
+
+func startingPeers(d *devnet.Devnet) []*peer.AddrInfo {
+	peers := d.BootstrapPeers()
+	startingPeers := make([]*peer.AddrInfo, len(peers))
+	for i := range peers {
+		startingPeers[i] = &peers[i]
+	}
+	return startingPeers
+}
+
+func sortedIDs(ids []peer.ID) []peer.ID {
+	sorted := append([]peer.ID{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func assertSamePeers(t *testing.T, expected, got []peer.ID) {
+	t.Helper()
+	expected, got = sortedIDs(expected), sortedIDs(got)
+	if len(expected) != len(got) {
+		t.Fatalf("expected peers %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Fatalf("expected peers %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestCrawlDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	namespaces := []string{dht.NsFull.String(), dht.NsArchival.String(), dht.NsLegacyFull.String()}
+	results := newCrawler(t, newHost(t)).Run(ctx, startingPeers(d)[:1], namespaces)
+
+	if got := len(results.GetSuccPeers()); got != devnetNodes {
+		t.Errorf("expected to crawl %d peers, crawled %d", devnetNodes, got)
+	}
+	if got := len(results.GetFailedPeers()); got != 0 {
+		t.Errorf("expected no failed peers, got %d: %v", got, results.GetFailures())
+	}
+	for _, namespace := range namespaces {
+		providers := make([]peer.ID, 0)
+		for p := range results.GetProvPeers(namespace) {
+			providers = append(providers, p)
+		}
+		assertSamePeers(t, d.Providers(namespace), providers)
+	}
+
+	agents := results.GetAgentDistributions()
+	if agents[devnet.AgentVersion] != devnetNodes {
+		t.Errorf("expected %d peers with agent %s, got %v", devnetNodes, devnet.AgentVersion, agents)
+	}
+
+	snapshot := results.Snapshot(devnet.Network)
+	if snapshot.Metadata.SuccPeers != devnetNodes || snapshot.Metadata.Providers[dht.NsFull.String()] != 3 {
+		t.Errorf("unexpected snapshot metadata: %+v", snapshot.Metadata)
+	}
+}
+
+func TestNeighborhoodCrawlDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	namespace := dht.NsFull.String()
+	results, traces := newCrawler(t, newHost(t)).RunNeighborhood(ctx, startingPeers(d)[:1], []string{namespace})
+
+	if len(traces) != 1 || !traces[0].Converged {
+		t.Fatalf("expected the neighborhood walk to converge, got %+v", traces)
+	}
+	providers := make([]peer.ID, 0)
+	for p := range results.GetProvPeers(namespace) {
+		providers = append(providers, p)
+	}
+	assertSamePeers(t, d.Providers(namespace), providers)
+}
+
+func TestNeighborhoodCrawlDevnetMaxPeers(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	crawler := newCrawler(t, newHost(t), dht.WithMaxPeers(3))
+	results, traces := crawler.RunNeighborhood(ctx, startingPeers(d)[:1], []string{dht.NsFull.String()})
+
+	if visited := len(results.GetSuccPeers()) + len(results.GetFailedPeers()); visited != 3 {
+		t.Errorf("expected the walk to visit 3 peers, got %d", visited)
+	}
+	if len(traces) != 1 || traces[0].Converged {
+		t.Errorf("expected the walk to stop before converging, got %+v", traces)
+	}
+	if status := results.GetStatus(); status != dht.CrawlStatusComplete {
+		t.Errorf("expected a complete crawl, got %s", status)
+	}
+}
+
+func TestNeighborhoodCrawlDevnetStopped(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// stop as soon as the first peer answers, before the neighborhood is asked for providers
+	stop := dht.CrawlObserverFunc(func(e dht.CrawlEvent) {
+		if _, ok := e.(dht.PeerConnected); ok {
+			cancel()
+		}
+	})
+	crawler := newCrawler(t, newHost(t), dht.WithObserver(stop))
+	results, _ := crawler.RunNeighborhood(ctx, startingPeers(d)[:1], []string{dht.NsFull.String()})
+
+	if len(results.GetSuccPeers()) == 0 {
+		t.Fatal("expected the first peer to be crawled")
+	}
+	if failures := results.GetQueryFailures(); len(failures) != 0 {
+		t.Errorf("expected no provider queries after the crawl stopped, got %v", failures)
+	}
+	if status := results.GetStatus(); status != dht.CrawlStatusStopped {
+		t.Errorf("expected a stopped crawl, got %s", status)
+	}
+}
+
+func TestLookupDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	disc := routingdisc.NewRoutingDiscovery(newClient(ctx, t, d))
+	streamed := 0
+	result, trace, err := dht.FindProvidersTraced(ctx, disc, dht.NsFull.String(), func(dht.FoundProvider) { streamed++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers := make([]peer.ID, 0)
+	for _, p := range result.Providers {
+		providers = append(providers, p.ID)
+	}
+	assertSamePeers(t, d.Providers(dht.NsFull.String()), providers)
+	if streamed != len(result.Providers) {
+		t.Errorf("expected every provider to be streamed, got %d of %d", streamed, len(result.Providers))
+	}
+	if result.TimeToFirstProvider() > result.TimeToLastProvider() {
+		t.Errorf("first provider after the last one: %s > %s", result.TimeToFirstProvider(), result.TimeToLastProvider())
+	}
+
+	if len(trace.Queries) == 0 {
+		t.Fatal("expected the trace to record queries")
+	}
+	for _, query := range trace.Queries {
+		if query.Error != "" {
+			t.Errorf("unexpected error querying %s: %s", query.Peer, query.Error)
+		}
+	}
+
+	stats := dht.AggregateLookups([]*dht.LookupResult{result, {Namespace: result.Namespace}})
+	if stats.Successful != 1 || len(stats.Union) != 3 || len(stats.Intersection) != 0 {
+		t.Errorf("unexpected lookup stats: %+v", stats)
+	}
+}
+
+func TestProvideDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// the last node of the devnet doesn't provide anything yet
+	provider := d.Nodes[len(d.Nodes)-1]
+	namespace := "custom-namespace"
+	if _, err := routingdisc.NewRoutingDiscovery(provider.DHT).Advertise(ctx, namespace); err != nil {
+		t.Fatal(err)
+	}
+
+	dhtCli := newClient(ctx, t, d)
+	pm, err := pb.NewProtocolMessenger(&dht.MessageSender{
+		H:         dhtCli.Host(),
+		Protocols: []protocol.ID{devnet.Network.KadProtocol()},
+		Timeout:   5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	holders, attempts, err := dht.WaitForRecordHolders(ctx, dhtCli, pm, namespace, provider.Host.ID(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 || len(holders) == 0 {
+		t.Errorf("expected the record to be visible on the first attempt, got %d holders after %d attempts", len(holders), attempts)
+	}
+	for _, holder := range holders {
+		if holder == provider.Host.ID() {
+			t.Error("the provider itself was counted as a holder")
+		}
+	}
+
+	// nobody provides the namespace from the first node
+	holders, _, err = dht.FindRecordHolders(ctx, dhtCli, pm, namespace, d.Nodes[0].Host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) != 0 {
+		t.Errorf("expected no holders for a provider that didn't advertise, got %v", holders)
+	}
+}
+
+func TestBootstrapCheckDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	addrs, err := d.BootstrapAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := addrs[:2]
+
+	checker := dht.NewBootstrapChecker(newHost(t), nil, devnet.Network.KadProtocol(), 5*time.Second)
+	checks, err := checker.Check(ctx, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range checks {
+		if !check.Healthy() {
+			t.Errorf("expected %s to be healthy: %+v", check.Entry, check.Dials)
+		}
+		if check.Dials[0].Transport != "tcp" || check.Dials[0].AgentVersion != devnet.AgentVersion {
+			t.Errorf("unexpected dial check %+v", check.Dials[0])
+		}
+	}
+
+	// the devnet doesn't speak the mainnet protocol
+	checker = dht.NewBootstrapChecker(newHost(t), nil, dht.Mainnet.KadProtocol(), 5*time.Second)
+	checks, err = checker.Check(ctx, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range checks {
+		if check.Healthy() {
+			t.Errorf("expected %s to be unhealthy for %s", check.Entry, dht.Mainnet.KadProtocol())
+		}
+	}
+}
+
+func TestClientDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	netConf, err := dht.DefaultRegistry().Get(devnet.Network.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := dht.NewClient(netConf,
+		dht.WithBootstrappers(d.BootstrapPeers()[:1]),
+		dht.WithHostOptions(libp2p.NoListenAddrs),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	namespace := dht.NsFull.String()
+	crawl, err := client.Crawl(ctx, dht.CrawlOptions{Namespaces: []string{namespace}, Parallelism: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(crawl.Results.GetSuccPeers()); got != devnetNodes {
+		t.Errorf("expected to crawl %d peers, crawled %d", devnetNodes, got)
+	}
+	providers := make([]peer.ID, 0)
+	for p := range crawl.Results.GetProvPeers(namespace) {
+		providers = append(providers, p)
+	}
+	assertSamePeers(t, d.Providers(namespace), providers)
+
+	lookup, err := client.Lookup(ctx, dht.LookupOptions{Namespace: namespace, MinRoutingTable: devnetNodes, Trace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lookup.Ready || lookup.Trace == nil {
+		t.Errorf("expected a ready routing table and a trace, got %+v", lookup)
+	}
+	providers = providers[:0]
+	for _, p := range lookup.Result.Providers {
+		providers = append(providers, p.ID)
+	}
+	assertSamePeers(t, d.Providers(namespace), providers)
+
+	boot, err := client.Bootstrap(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boot.Connected) != 1 || len(boot.Failed) != 0 {
+		t.Errorf("unexpected bootstrap report %+v", boot)
+	}
+
+	if _, err := client.Crawl(ctx, dht.CrawlOptions{Mode: "unknown"}); err == nil {
+		t.Error("expected an error for an unknown crawl mode")
+	}
+}
+
+func TestClientProvideDevnet(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	netConf, err := dht.DefaultRegistry().Get(devnet.Network.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func(opts ...dht.ClientOption) *dht.Client {
+		opts = append([]dht.ClientOption{dht.WithBootstrappers(d.BootstrapPeers()[:1])}, opts...)
+		client, err := dht.NewClient(netConf, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		return client
+	}
+	provider := newClient(dht.WithServerMode(), dht.WithHostOptions(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")))
+	verifier := newClient(dht.WithHostOptions(libp2p.NoListenAddrs))
+
+	// a legacy namespace, so that the devnet providers don't hold it already
+	namespace := dht.NsLegacyArchival.String()
+	report, err := provider.Provide(ctx, dht.ProvideOptions{Namespace: namespace, MinRoutingTable: devnetNodes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Ready || report.TTL <= 0 {
+		t.Errorf("unexpected provide report %+v", report)
+	}
+
+	holders, attempts, err := verifier.WaitForRecordHolders(ctx, dht.RecordHoldersOptions{
+		Namespace:       namespace,
+		Provider:        provider.ID(),
+		Interval:        time.Second,
+		MinRoutingTable: devnetNodes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) == 0 || attempts != 1 {
+		t.Errorf("expected the record to be held after a single attempt, got %d holders after %d", len(holders), attempts)
+	}
+	for _, holder := range holders {
+		if holder == provider.ID() {
+			t.Error("the provider was counted as a holder of its own record")
+		}
+	}
+
+	addrs, err := d.BootstrapAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := verifier.CheckBootstrappers(ctx, addrs[:1], 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || !checks[0].Healthy() {
+		t.Errorf("expected a single healthy bootstrapper, got %+v", checks)
+	}
+}
@@ -0,0 +1,90 @@
+package dht
+
+import (
+	"context"
+	"time"
+
+	kad "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// routingTablePollInterval is how often the routing table is checked while waiting for the bootstrap
+const routingTablePollInterval = 100 * time.Millisecond
+
+// WaitForRoutingTable blocks until the routing table of the DHT client holds at least
+// minSize peers, or until the timeout expires. It returns the size of the routing table,
+// the time it took and whether the size was reached
+func WaitForRoutingTable(ctx context.Context, dhtCli *kad.IpfsDHT, minSize int, timeout time.Duration) (int, time.Duration, bool) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(routingTablePollInterval)
+	defer ticker.Stop()
+	for {
+		size := dhtCli.RoutingTable().Size()
+		if size >= minSize {
+			return size, time.Since(start), true
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return dhtCli.RoutingTable().Size(), time.Since(start), false
+		}
+	}
+}
+
+// FoundProvider is a provider returned by a lookup, with the time since the lookup started
+type FoundProvider struct {
+	peer.AddrInfo
+	Elapsed time.Duration
+}
+
+// LookupResult summarizes the providers found for a namespace
+type LookupResult struct {
+	Namespace string
+	Providers []FoundProvider
+	// Duration is the time until the lookup completed or timed out
+	Duration time.Duration
+}
+
+// TimeToFirstProvider returns the time until the first provider was found, or zero if there was none
+func (r *LookupResult) TimeToFirstProvider() time.Duration {
+	if len(r.Providers) == 0 {
+		return 0
+	}
+	return r.Providers[0].Elapsed
+}
+
+// TimeToLastProvider returns the time until the last provider was found, or zero if there was none
+func (r *LookupResult) TimeToLastProvider() time.Duration {
+	if len(r.Providers) == 0 {
+		return 0
+	}
+	return r.Providers[len(r.Providers)-1].Elapsed
+}
+
+// FindProviders looks the namespace up, calling onProvider (if not nil) as soon as each provider
+// arrives. It returns once the lookup is exhausted or the context is done
+func FindProviders(ctx context.Context, disc discovery.Discoverer, namespace string, onProvider func(FoundProvider)) (*LookupResult, error) {
+	result := &LookupResult{
+		Namespace: namespace,
+		Providers: make([]FoundProvider, 0),
+	}
+
+	start := time.Now()
+	peers, err := disc.FindPeers(ctx, namespace, discovery.Limit(0))
+	if err != nil {
+		return nil, err
+	}
+	for p := range peers {
+		provider := FoundProvider{AddrInfo: p, Elapsed: time.Since(start)}
+		result.Providers = append(result.Providers, provider)
+		if onProvider != nil {
+			onProvider(provider)
+		}
+	}
+	result.Duration = time.Since(start)
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package dht
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type FreshMode string
+
+func (m FreshMode) String() string { return string(m) }
+
+const (
+	// FreshNone reuses the same DHT client for every lookup
+	FreshNone FreshMode = "none"
+	// FreshClient creates a new host and DHT client, with the same identity, for every lookup
+	FreshClient FreshMode = "client"
+	// FreshIdentity creates a new host and DHT client, with a new ephemeral identity, for every lookup
+	FreshIdentity FreshMode = "identity"
+)
+
+func FreshModeFromString(mode string) (FreshMode, error) {
+	switch strings.ToLower(mode) {
+	case FreshNone.String():
+		return FreshNone, nil
+	case FreshClient.String():
+		return FreshClient, nil
+	case FreshIdentity.String():
+		return FreshIdentity, nil
+	default:
+		return "", fmt.Errorf("unknown fresh mode: %q", mode)
+	}
+}
+
+// LookupStats aggregates the results of repeated lookups of the same namespace
+type LookupStats struct {
+	Runs       int
+	Successful int
+	// ProviderCounts maps the number of providers found to the number of runs that found them
+	ProviderCounts map[int]int
+	// TimeToFirst and TimeToLast only cover the successful runs, Duration covers all of them
+	TimeToFirst Percentiles
+	TimeToLast  Percentiles
+	Duration    Percentiles
+	// Union are the providers found in any run, Intersection the ones found in every run
+	Union        []peer.ID
+	Intersection []peer.ID
+}
+
+// SuccessRate is the fraction of runs that found at least one provider
+func (s *LookupStats) SuccessRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Successful) / float64(s.Runs)
+}
+
+// Percentiles summarizes a set of latencies
+type Percentiles struct {
+	Min, P50, P90, P99, Max time.Duration
+}
+
+// AggregateLookups computes the stats of a set of lookups
+func AggregateLookups(results []*LookupResult) *LookupStats {
+	stats := &LookupStats{
+		Runs:           len(results),
+		ProviderCounts: make(map[int]int),
+	}
+
+	var ttfs, ttls, durations []time.Duration
+	seen := make(map[peer.ID]int)
+	for _, result := range results {
+		stats.ProviderCounts[len(result.Providers)]++
+		durations = append(durations, result.Duration)
+		if len(result.Providers) > 0 {
+			stats.Successful++
+			ttfs = append(ttfs, result.TimeToFirstProvider())
+			ttls = append(ttls, result.TimeToLastProvider())
+		}
+
+		found := make(map[peer.ID]struct{}, len(result.Providers))
+		for _, p := range result.Providers {
+			found[p.ID] = struct{}{}
+		}
+		for p := range found {
+			seen[p]++
+		}
+	}
+	stats.TimeToFirst = percentiles(ttfs)
+	stats.TimeToLast = percentiles(ttls)
+	stats.Duration = percentiles(durations)
+
+	stats.Union = make([]peer.ID, 0, len(seen))
+	stats.Intersection = make([]peer.ID, 0)
+	for p, runs := range seen {
+		stats.Union = append(stats.Union, p)
+		if runs == len(results) {
+			stats.Intersection = append(stats.Intersection, p)
+		}
+	}
+	sort.Slice(stats.Union, func(i, j int) bool { return stats.Union[i] < stats.Union[j] })
+	sort.Slice(stats.Intersection, func(i, j int) bool { return stats.Intersection[i] < stats.Intersection[j] })
+	return stats
+}
+
+// percentiles uses the nearest-rank method
+func percentiles(values []time.Duration) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p int) time.Duration {
+		idx := (p*len(sorted)+99)/100 - 1
+		return sorted[max(idx, 0)]
+	}
+	return Percentiles{
+		Min: sorted[0],
+		P50: rank(50),
+		P90: rank(90),
+		P99: rank(99),
+		Max: sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,98 @@
+package dht
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPercentiles(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		durations := make([]time.Duration, len(values))
+		for i, v := range values {
+			durations[i] = time.Duration(v) * time.Millisecond
+		}
+		return durations
+	}
+	seq := func(n int) []int {
+		values := make([]int, n)
+		for i := range values {
+			values[i] = n - i // reversed, so that they have to be sorted
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values []time.Duration
+		// want are the min, p50, p90, p99 and max in milliseconds
+		want []int
+	}{
+		{"empty", nil, []int{0, 0, 0, 0, 0}},
+		{"one sample", ms(7), []int{7, 7, 7, 7, 7}},
+		{"even count", ms(4, 1, 3, 2), []int{1, 2, 4, 4, 4}},
+		{"odd count", ms(50, 10, 40, 20, 30), []int{10, 30, 50, 50, 50}},
+		{"ten samples", ms(seq(10)...), []int{1, 5, 9, 10, 10}},
+		{"hundred samples", ms(seq(100)...), []int{1, 50, 90, 99, 100}},
+		{"repeated values", ms(3, 3, 1, 3), []int{1, 3, 3, 3, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := slices.Clone(tt.values)
+			p := percentiles(tt.values)
+			got := []time.Duration{p.Min, p.P50, p.P90, p.P99, p.Max}
+			if want := ms(tt.want...); !slices.Equal(got, want) {
+				t.Errorf("expected min/p50/p90/p99/max %v, got %v", want, got)
+			}
+			if !slices.Equal(input, tt.values) {
+				t.Errorf("the values were modified: %v", tt.values)
+			}
+		})
+	}
+}
+
+func TestAggregateLookups(t *testing.T) {
+	found := func(ids []peer.ID, elapsed ...time.Duration) []FoundProvider {
+		providers := make([]FoundProvider, len(ids))
+		for i, id := range ids {
+			providers[i] = FoundProvider{AddrInfo: peer.AddrInfo{ID: id}, Elapsed: elapsed[i]}
+		}
+		return providers
+	}
+	results := []*LookupResult{
+		{Providers: found([]peer.ID{"a", "b"}, time.Second, 3*time.Second), Duration: 4 * time.Second},
+		{Providers: found([]peer.ID{"b", "c", "b"}, 2*time.Second, 2*time.Second, 5*time.Second), Duration: 6 * time.Second},
+		{Duration: 10 * time.Second},
+	}
+
+	stats := AggregateLookups(results)
+	if stats.Runs != 3 || stats.Successful != 2 || stats.SuccessRate() != 2.0/3 {
+		t.Errorf("unexpected runs: %d successful of %d", stats.Successful, stats.Runs)
+	}
+	if stats.ProviderCounts[0] != 1 || stats.ProviderCounts[2] != 1 || stats.ProviderCounts[3] != 1 {
+		t.Errorf("unexpected provider counts %v", stats.ProviderCounts)
+	}
+	// the failed run only counts for the duration
+	if stats.TimeToFirst.Min != time.Second || stats.TimeToFirst.Max != 2*time.Second || stats.TimeToLast.P50 != 3*time.Second {
+		t.Errorf("unexpected times to the first and last provider: %+v %+v", stats.TimeToFirst, stats.TimeToLast)
+	}
+	if stats.Duration.P50 != 6*time.Second || stats.Duration.Max != 10*time.Second {
+		t.Errorf("unexpected durations %+v", stats.Duration)
+	}
+	if !slices.Equal(stats.Union, []peer.ID{"a", "b", "c"}) {
+		t.Errorf("unexpected union %v", stats.Union)
+	}
+	// no provider is found by the run that failed
+	if len(stats.Intersection) != 0 {
+		t.Errorf("unexpected intersection %v", stats.Intersection)
+	}
+	if common := AggregateLookups(results[:2]).Intersection; !slices.Equal(common, []peer.ID{"b"}) {
+		t.Errorf("expected b to be found in both successful runs, got %v", common)
+	}
+
+	if empty := AggregateLookups(nil); empty.Runs != 0 || empty.SuccessRate() != 0 || len(empty.Union) != 0 {
+		t.Errorf("unexpected stats without lookups %+v", empty)
+	}
+}
@@ -39,7 +39,8 @@ func (ms *MessageSender) SendRequest(ctx context.Context, p peer.ID, pmes *pb.Me
 	msg := new(pb.Message)
 	if err := ctxReadMsg(tctx, r, msg); err != nil {
 		_ = s.Reset()
-		return nil, err
+		// the stream is open, so a timeout comes from the peer not answering
+		return nil, asResponseTimeout(err)
 	}
 
 	return msg, nil
@@ -0,0 +1,172 @@
+package dht
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+// newMessengerPair connects a MessageSender to a remote host that handles the DHT protocol with the given handler
+func newMessengerPair(t *testing.T, timeout time.Duration, handler network.StreamHandler) (*MessageSender, host.Host) {
+	t.Helper()
+	mn := mocknet.New()
+	t.Cleanup(func() { _ = mn.Close() })
+
+	local, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mn.ConnectPeers(local.ID(), remote.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if handler != nil {
+		remote.SetStreamHandler(Private.KadProtocol(), handler)
+	}
+
+	ms := &MessageSender{H: local, Protocols: []protocol.ID{Private.KadProtocol()}, Timeout: timeout}
+	return ms, remote
+}
+
+func readRequest(t *testing.T, s network.Stream) *pb.Message {
+	req := new(pb.Message)
+	if err := protoio.NewDelimitedReader(s, network.MessageSizeMax).ReadMsg(req); err != nil {
+		t.Errorf("reading the request: %v", err)
+		return nil
+	}
+	return req
+}
+
+func findNodeRequest() *pb.Message {
+	return pb.NewMessage(pb.Message_FIND_NODE, []byte("key"), 0)
+}
+
+func TestMessageSenderSendRequest(t *testing.T) {
+	ms, remote := newMessengerPair(t, time.Second, func(s network.Stream) {
+		defer s.Close()
+		req := readRequest(t, s)
+		if req == nil {
+			return
+		}
+		resp := pb.NewMessage(req.GetType(), req.GetKey(), 0)
+		if err := protoio.NewDelimitedWriter(s).WriteMsg(resp); err != nil {
+			t.Errorf("writing the response: %v", err)
+		}
+	})
+
+	resp, err := ms.SendRequest(context.Background(), remote.ID(), findNodeRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetType() != pb.Message_FIND_NODE || string(resp.GetKey()) != "key" {
+		t.Errorf("unexpected response %v", resp)
+	}
+}
+
+func TestMessageSenderTimeout(t *testing.T) {
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	ms, remote := newMessengerPair(t, 100*time.Millisecond, func(s network.Stream) {
+		readRequest(t, s)
+		// never answer
+		<-done
+		_ = s.Reset()
+	})
+
+	start := time.Now()
+	_, err := ms.SendRequest(context.Background(), remote.ID(), findNodeRequest())
+	if !errors.Is(err, context.DeadlineExceeded) || ClassifyError(err) != FailureResponseTimeout {
+		t.Fatalf("expected a response timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the timeout took %s to kick in", elapsed)
+	}
+
+	// the caller's context is honored as well
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ms.SendRequest(ctx, remote.ID(), findNodeRequest()); err == nil {
+		t.Error("expected an error with a canceled context")
+	}
+}
+
+func TestMessageSenderReset(t *testing.T) {
+	ms, remote := newMessengerPair(t, time.Second, func(s network.Stream) {
+		readRequest(t, s)
+		_ = s.Reset()
+	})
+
+	_, err := ms.SendRequest(context.Background(), remote.ID(), findNodeRequest())
+	if err == nil {
+		t.Fatal("expected an error after the stream reset")
+	}
+	if category := ClassifyError(err); category != FailureStreamReset {
+		t.Errorf("expected %s, got %s (%v)", FailureStreamReset, category, err)
+	}
+}
+
+func TestMessageSenderOversizedMessage(t *testing.T) {
+	ms, remote := newMessengerPair(t, time.Second, func(s network.Stream) {
+		defer s.Close()
+		readRequest(t, s)
+		// announce a message over the size limit, followed by some of its bytes
+		buf := binary.AppendUvarint(nil, uint64(network.MessageSizeMax+1))
+		_, _ = s.Write(append(buf, make([]byte, 1024)...))
+	})
+
+	_, err := ms.SendRequest(context.Background(), remote.ID(), findNodeRequest())
+	if err == nil {
+		t.Fatal("expected an error for an oversized response")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the oversized response to be rejected before the timeout, got %v", err)
+	}
+}
+
+func TestMessageSenderUnsupportedProtocol(t *testing.T) {
+	ms, remote := newMessengerPair(t, time.Second, nil)
+
+	_, err := ms.SendRequest(context.Background(), remote.ID(), findNodeRequest())
+	if err == nil {
+		t.Fatal("expected an error for a peer without the DHT protocol")
+	}
+	if category := ClassifyError(err); category != FailureProtocolNotSupported {
+		t.Errorf("expected %s, got %s (%v)", FailureProtocolNotSupported, category, err)
+	}
+}
+
+func TestMessageSenderSendMessage(t *testing.T) {
+	received := make(chan *pb.Message, 1)
+	ms, remote := newMessengerPair(t, time.Second, func(s network.Stream) {
+		defer s.Close()
+		received <- readRequest(t, s)
+	})
+
+	msg := pb.NewMessage(pb.Message_ADD_PROVIDER, []byte("key"), 0)
+	if err := ms.SendMessage(context.Background(), remote.ID(), msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-received:
+		if got.GetType() != pb.Message_ADD_PROVIDER || string(got.GetKey()) != "key" {
+			t.Errorf("unexpected message %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message never arrived")
+	}
+}
@@ -0,0 +1,452 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	kad "github.com/libp2p/go-libp2p-kad-dht"
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+// topologyShape returns the pairs of nodes that are connected to each other
+type topologyShape func(n int) [][2]int
+
+// ringShape connects every node to the next one, and the last one to the first one
+func ringShape(n int) [][2]int {
+	edges := make([][2]int, 0, n)
+	for i := 0; i < n; i++ {
+		edges = append(edges, [2]int{i, (i + 1) % n})
+	}
+	return edges
+}
+
+// starShape connects every node to the first one
+func starShape(n int) [][2]int {
+	edges := make([][2]int, 0, n-1)
+	for i := 1; i < n; i++ {
+		edges = append(edges, [2]int{0, i})
+	}
+	return edges
+}
+
+// mockTopology is a synthetic Celestia DHT on top of libp2p's mocknet, with a host
+// to crawl it from and provider records at known places
+type mockTopology struct {
+	t       *testing.T
+	mn      mocknet.Mocknet
+	nodes   []*kad.IpfsDHT
+	crawler host.Host
+}
+
+func newMockTopology(t *testing.T, n int, shape topologyShape) *mockTopology {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mn := mocknet.New()
+	t.Cleanup(func() { _ = mn.Close() })
+
+	topo := &mockTopology{t: t, mn: mn, nodes: make([]*kad.IpfsDHT, 0, n)}
+	for i := 0; i < n; i++ {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := kad.New(ctx, h,
+			kad.Mode(kad.ModeServer),
+			kad.ProtocolPrefix(Private.KadPrefix()),
+			kad.DisableAutoRefresh(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = node.Close() })
+		topo.nodes = append(topo.nodes, node)
+	}
+
+	crawler, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo.crawler = crawler
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	degrees := make([]int, n)
+	for _, edge := range shape(n) {
+		if _, err := mn.ConnectPeers(topo.id(edge[0]), topo.id(edge[1])); err != nil {
+			t.Fatal(err)
+		}
+		degrees[edge[0]]++
+		degrees[edge[1]]++
+	}
+
+	// the peers join the routing tables once identify confirms they speak the DHT protocol
+	for i, node := range topo.nodes {
+		for node.RoutingTable().Size() < degrees[i] {
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-ctx.Done():
+				t.Fatalf("routing table of node %d has %d of %d peers", i, node.RoutingTable().Size(), degrees[i])
+			}
+		}
+	}
+	return topo
+}
+
+func (m *mockTopology) id(i int) peer.ID { return m.nodes[i].Host().ID() }
+
+func (m *mockTopology) ids(idxs ...int) []peer.ID {
+	ids := make([]peer.ID, 0, len(idxs))
+	for _, i := range idxs {
+		ids = append(ids, m.id(i))
+	}
+	return ids
+}
+
+// place stores the record of the provider node for the namespace at the holder nodes
+func (m *mockTopology) place(namespace string, provider int, holders ...int) {
+	m.t.Helper()
+	recordCid, err := KeyToCid(namespace)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	for _, holder := range holders {
+		err := m.nodes[holder].ProviderStore().AddProvider(context.Background(), recordCid.Hash(), peer.AddrInfo{ID: m.id(provider)})
+		if err != nil {
+			m.t.Fatal(err)
+		}
+	}
+}
+
+// unreachable prevents the crawler from dialing the node, which stays connected to the rest
+func (m *mockTopology) unreachable(i int) {
+	m.t.Helper()
+	if err := m.mn.UnlinkPeers(m.crawler.ID(), m.id(i)); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+// silent adds a peer next to the given node that speaks the DHT protocol, but doesn't answer the
+// requests of the crawler of the given types (none of them if no type is given). The other
+// requests get empty answers, so that the nodes keep the peer in their routing tables
+func (m *mockTopology) silent(i int, types ...pb.Message_MessageType) peer.ID {
+	m.t.Helper()
+	h, err := m.mn.GenPeer()
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	done := make(chan struct{})
+	m.t.Cleanup(func() { close(done) })
+	h.SetStreamHandler(Private.KadProtocol(), func(s network.Stream) {
+		defer func() { _ = s.Reset() }()
+		r, w := protoio.NewDelimitedReader(s, network.MessageSizeMax), protoio.NewDelimitedWriter(s)
+		for {
+			req := new(pb.Message)
+			if err := r.ReadMsg(req); err != nil {
+				return
+			}
+			if s.Conn().RemotePeer() == m.crawler.ID() && (len(types) == 0 || slices.Contains(types, req.GetType())) {
+				<-done
+				return
+			}
+			if err := w.WriteMsg(pb.NewMessage(req.GetType(), req.GetKey(), 0)); err != nil {
+				return
+			}
+		}
+	})
+	if err := m.mn.LinkAll(); err != nil {
+		m.t.Fatal(err)
+	}
+
+	// the node adds the peer to its routing table once identify confirms the protocol
+	rtSize := m.nodes[i].RoutingTable().Size()
+	if _, err := m.mn.ConnectPeers(m.id(i), h.ID()); err != nil {
+		m.t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for m.nodes[i].RoutingTable().Size() <= rtSize {
+		if time.Now().After(deadline) {
+			m.t.Fatalf("the silent peer didn't join the routing table of node %d", i)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return h.ID()
+}
+
+// crawl runs a BaseCrawler from the first node of the topology
+func (m *mockTopology) crawl(namespaces []string, opts ...CrawlerOption) *CrawlResults {
+	m.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return m.crawlContext(ctx, namespaces, opts...)
+}
+
+// crawlContext is like crawl, but the crawl stops once the given context is done
+func (m *mockTopology) crawlContext(ctx context.Context, namespaces []string, opts ...CrawlerOption) *CrawlResults {
+	m.t.Helper()
+	return m.run(ctx, m.newCrawler(opts...), namespaces)
+}
+
+// newCrawler creates a BaseCrawler on the crawler host of the topology
+func (m *mockTopology) newCrawler(opts ...CrawlerOption) *BaseCrawler {
+	m.t.Helper()
+	prots := []protocol.ID{Private.KadProtocol()}
+	pm, err := pb.NewProtocolMessenger(&MessageSender{H: m.crawler, Protocols: prots, Timeout: time.Second})
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	crawler, err := New(m.crawler, prots, pm, append([]CrawlerOption{WithConnectTimeout(time.Second)}, opts...)...)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return crawler
+}
+
+// run crawls the topology with the given crawler from its first node
+func (m *mockTopology) run(ctx context.Context, crawler *BaseCrawler, namespaces []string) *CrawlResults {
+	m.t.Helper()
+	start := m.nodes[0].Host()
+	results := crawler.Run(ctx, []*peer.AddrInfo{{ID: start.ID(), Addrs: start.Addrs()}}, namespaces)
+	if results == nil {
+		m.t.Fatal("crawl returned no results")
+	}
+	return results
+}
+
+func assertPeerSet[V any](t *testing.T, name string, expected []peer.ID, got map[peer.ID]V) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Errorf("%s: expected %d peers, got %d", name, len(expected), len(got))
+	}
+	for _, p := range expected {
+		if _, ok := got[p]; !ok {
+			t.Errorf("%s: missing peer %s", name, p)
+		}
+	}
+}
+
+func TestCrawlerRunFindsEveryPeer(t *testing.T) {
+	shapes := map[string]topologyShape{
+		"ring": ringShape,
+		"star": starShape,
+	}
+	for name, shape := range shapes {
+		t.Run(name, func(t *testing.T) {
+			topo := newMockTopology(t, 12, shape)
+			results := topo.crawl([]string{NsFull.String()})
+
+			all := make([]int, len(topo.nodes))
+			for i := range all {
+				all[i] = i
+			}
+			assertPeerSet(t, "crawled peers", topo.ids(all...), results.GetSuccPeers())
+			if failed := results.GetFailedPeers(); len(failed) != 0 {
+				t.Errorf("expected no failed peers, got %v", results.GetFailures())
+			}
+
+			// every node reports its own routing table, which holds at least its direct connections
+			neighbors := results.GetNeighbors()
+			for _, edge := range shape(len(topo.nodes)) {
+				found := false
+				for _, p := range neighbors[topo.id(edge[0])] {
+					found = found || p == topo.id(edge[1])
+				}
+				if !found {
+					t.Errorf("node %d doesn't report node %d as neighbor", edge[0], edge[1])
+				}
+			}
+
+			agents := results.GetAgentDistributions()
+			if agents["total"] != len(topo.nodes) || len(agents) != 2 {
+				t.Errorf("expected a single agent version for the %d nodes, got %v", len(topo.nodes), agents)
+			}
+		})
+	}
+}
+
+func TestCrawlerRunProviderPlacement(t *testing.T) {
+	topo := newMockTopology(t, 10, ringShape)
+	full, archival, legacy := NsFull.String(), NsArchival.String(), NsLegacyFull.String()
+	topo.place(full, 1, 2, 3, 4)
+	topo.place(full, 5, 5, 6)
+	topo.place(archival, 7, 0)
+
+	results := topo.crawl([]string{full, archival, legacy})
+
+	assertPeerSet(t, "full providers", topo.ids(1, 5), results.GetProvPeers(full))
+	assertPeerSet(t, "archival providers", topo.ids(7), results.GetProvPeers(archival))
+	assertPeerSet(t, "legacy providers", nil, results.GetProvPeers(legacy))
+
+	expectedHolders := map[peer.ID][]peer.ID{
+		topo.id(1): topo.ids(2, 3, 4),
+		topo.id(5): topo.ids(5, 6),
+	}
+	holders := results.GetProvHolders(full)
+	for provider, expected := range expectedHolders {
+		got := make(map[peer.ID]struct{})
+		for _, holder := range holders[provider] {
+			got[holder] = struct{}{}
+		}
+		assertPeerSet(t, fmt.Sprintf("holders of %s", provider), expected, got)
+	}
+
+	replication := results.GetReplicationFactors(full)
+	if replication[topo.id(1)] != 3 || replication[topo.id(5)] != 2 {
+		t.Errorf("unexpected replication factors: %v", replication)
+	}
+	counts := results.GetProvCounts()
+	if counts[full] != 2 || counts[archival] != 1 || counts[legacy] != 0 {
+		t.Errorf("unexpected provider counts: %v", counts)
+	}
+
+	snapshot := results.Snapshot(Private)
+	if snapshot.Metadata.Providers[full] != 2 || len(snapshot.Providers) != 3 {
+		t.Errorf("unexpected providers in the snapshot: %+v", snapshot.Providers)
+	}
+}
+
+func TestCrawlerRunMaxPeers(t *testing.T) {
+	topo := newMockTopology(t, 8, starShape)
+	full := NsFull.String()
+	topo.place(full, 7, 0, 1, 2, 3, 4, 5, 6, 7)
+
+	results := topo.crawl([]string{full}, WithMaxPeers(3))
+
+	// the crawl stops at the limit, but every visited peer, including the last one, was asked for the providers
+	succ := results.GetSuccPeers()
+	if len(succ) != 3 || len(results.GetFailedPeers()) != 0 {
+		t.Fatalf("expected 3 visited peers, got %d and %d failed", len(succ), len(results.GetFailedPeers()))
+	}
+	if failures := results.GetQueryFailures(); len(failures) != 0 {
+		t.Errorf("unexpected provider query failures: %v", failures)
+	}
+	holders := make(map[peer.ID]struct{})
+	for _, holder := range results.GetProvHolders(full)[topo.id(7)] {
+		holders[holder] = struct{}{}
+	}
+	expected := make([]peer.ID, 0, len(succ))
+	for p := range succ {
+		expected = append(expected, p)
+	}
+	assertPeerSet(t, "holders", expected, holders)
+	if results.GetStatus() != CrawlStatusComplete {
+		t.Errorf("expected a complete crawl, got %s", results.GetStatus())
+	}
+}
+
+func TestCrawlerRunUnreachablePeers(t *testing.T) {
+	// in a star every node is still discovered through the first one
+	topo := newMockTopology(t, 8, starShape)
+	topo.unreachable(3)
+	topo.unreachable(6)
+
+	results := topo.crawl([]string{NsFull.String()})
+
+	assertPeerSet(t, "crawled peers", topo.ids(0, 1, 2, 4, 5, 7), results.GetSuccPeers())
+	assertPeerSet(t, "failed peers", topo.ids(3, 6), results.GetFailedPeers())
+	failures := results.GetFailures()
+	for _, p := range topo.ids(3, 6) {
+		if failures[p].Error == "" || failures[p].Category == "" {
+			t.Errorf("expected a classified error for %s, got %+v", p, failures[p])
+		}
+	}
+	if dist := results.GetFailureDistributions(); dist["total"] != 2 {
+		t.Errorf("expected 2 failures in the distribution, got %v", dist)
+	}
+
+	meta := results.Snapshot(Private).Metadata
+	if meta.TotalPeers != 8 || meta.SuccPeers != 6 || meta.FailPeers != 2 {
+		t.Errorf("unexpected snapshot counts: %+v", meta)
+	}
+}
+
+func TestCrawlerRunSilentPeer(t *testing.T) {
+	topo := newMockTopology(t, 3, starShape)
+	silent := topo.silent(0)
+
+	results := topo.crawl([]string{NsFull.String()}, WithMsgTimeout(300*time.Millisecond))
+
+	// the dial succeeded, so the peer failed on the FIND_NODE requests
+	assertPeerSet(t, "crawled peers", topo.ids(0, 1, 2), results.GetSuccPeers())
+	assertPeerSet(t, "failed peers", []peer.ID{silent}, results.GetFailedPeers())
+	if failure := results.GetFailures()[silent]; failure.Category != FailureResponseTimeout {
+		t.Errorf("expected a response timeout, got %+v", failure)
+	}
+}
+
+func TestCrawlerRunNeighborhoodQueryFailed(t *testing.T) {
+	topo := newMockTopology(t, 3, starShape)
+	silent := topo.silent(0, pb.Message_GET_PROVIDERS)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	full := NsFull.String()
+	start := topo.nodes[0].Host()
+	results, traces := topo.newCrawler().RunNeighborhood(ctx, []*peer.AddrInfo{{ID: start.ID(), Addrs: start.Addrs()}}, []string{full})
+	if results == nil || len(traces) != 1 {
+		t.Fatalf("expected the results and a trace, got %v and %d traces", results, len(traces))
+	}
+
+	// the neighborhood and the placement report of the results agree on the peer's status
+	report, err := results.PlacementReport(full, KademliaK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string][]ClosestPeer{"neighborhood": traces[0].Closest, "placement": report.Closest}
+	for name, closest := range statuses {
+		i := slices.IndexFunc(closest, func(p ClosestPeer) bool { return p.ID == silent })
+		if i < 0 {
+			t.Errorf("expected the silent peer in the %s closest peers, got %+v", name, closest)
+		} else if closest[i].Status != PeerStatusQueryFailed {
+			t.Errorf("expected the %s status %s, got %s", name, PeerStatusQueryFailed, closest[i].Status)
+		}
+	}
+}
+
+func TestCrawlerRunTwice(t *testing.T) {
+	topo := newMockTopology(t, 6, ringShape)
+	full, archival := NsFull.String(), NsArchival.String()
+	topo.place(full, 1, 2)
+	topo.place(archival, 3, 4)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	crawler := topo.newCrawler()
+	first := topo.run(ctx, crawler, []string{full})
+	// the crawler no longer reaches the last node, and drops its connection from the first run
+	topo.unreachable(5)
+	if err := topo.crawler.Network().ClosePeer(topo.id(5)); err != nil {
+		t.Fatal(err)
+	}
+	second := topo.run(ctx, crawler, []string{archival})
+
+	if first == second {
+		t.Fatal("expected each run to return its own results")
+	}
+	// the first results are left as they were
+	assertPeerSet(t, "first crawled peers", topo.ids(0, 1, 2, 3, 4, 5), first.GetSuccPeers())
+	assertPeerSet(t, "first failed peers", nil, first.GetFailedPeers())
+	assertPeerSet(t, "first archival providers", nil, first.GetProvPeers(archival))
+	if keys := first.GetRecordKeys(); len(keys) != 1 || keys[0] != full {
+		t.Errorf("unexpected keys of the first run %v", keys)
+	}
+
+	assertPeerSet(t, "second crawled peers", topo.ids(0, 1, 2, 3, 4), second.GetSuccPeers())
+	assertPeerSet(t, "second failed peers", topo.ids(5), second.GetFailedPeers())
+	assertPeerSet(t, "second full providers", nil, second.GetProvPeers(full))
+	assertPeerSet(t, "second archival providers", topo.ids(3), second.GetProvPeers(archival))
+	if first.GetStatus() != CrawlStatusComplete || second.GetStatus() != CrawlStatusComplete {
+		t.Errorf("expected both runs to be complete, got %s and %s", first.GetStatus(), second.GetStatus())
+	}
+}
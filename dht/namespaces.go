@@ -0,0 +1,89 @@
+package dht
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// NamespaceAliases are the short names accepted in place of the namespaces' DHT keys.
+// The bare "full" and "archival" refer to the current namespaces, while the keys used
+// by older nodes are reached through their "legacy-" aliases
+var NamespaceAliases = map[string][]NodeType{
+	"full":            {NsFull},
+	"archival":        {NsArchival},
+	"legacy-full":     {NsLegacyFull},
+	"legacy-archival": {NsLegacyArchival},
+	"all":             NodeTypes,
+}
+
+// DefaultNetworkNamespaces are the namespaces of the networks that don't list their own.
+// They are given as aliases, so they resolve to every known node type
+var DefaultNetworkNamespaces = []string{"full", "archival", "legacy-archival", "legacy-full"}
+
+// ResolveNamespaces translates the given namespaces or aliases into DHT keys.
+// Unless custom is set, only the aliases, the known node types and the namespaces of
+// the network are accepted, so that a typo fails instead of silently finding no providers.
+// With custom set, the names are used as free-form keys
+func (n *NetworkConfig) ResolveNamespaces(names []string, custom bool) ([]string, error) {
+	keys := make([]string, 0, len(names))
+	seen := make(map[string]struct{}, len(names))
+	add := func(key string) {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+
+	var errs []error
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			errs = append(errs, fmt.Errorf("empty namespace"))
+		case custom:
+			add(name)
+		case NamespaceAliases[name] != nil:
+			for _, nodeType := range NamespaceAliases[name] {
+				add(nodeType.String())
+			}
+		case slices.Contains(n.knownNamespaces(), name):
+			add(name)
+		default:
+			errs = append(errs, fmt.Errorf("unknown namespace %q", name))
+		}
+	}
+	if len(errs) > 0 {
+		// keys shadowed by an alias can only be reached through it
+		keys := slices.DeleteFunc(n.knownNamespaces(), func(key string) bool { return NamespaceAliases[key] != nil })
+		errs = append(errs, fmt.Errorf("expected one of %s, a known key (%s) or --is-custom for any other key",
+			strings.Join(aliasNames(), ", "), strings.Join(keys, ", ")))
+		return nil, errors.Join(errs...)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no namespace given")
+	}
+	return keys, nil
+}
+
+// knownNamespaces are the node types plus the namespaces configured for the network
+func (n *NetworkConfig) knownNamespaces() []string {
+	known := DefaultCrawlNamespaces()
+	for _, ns := range n.Namespaces {
+		if !slices.Contains(known, ns) {
+			known = append(known, ns)
+		}
+	}
+	return known
+}
+
+func aliasNames() []string {
+	names := make([]string, 0, len(NamespaceAliases))
+	for name := range NamespaceAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,63 @@
+package dht
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveNamespaces(t *testing.T) {
+	net := &NetworkConfig{Name: "test", Namespaces: []string{"/custom/v0.1.0"}}
+	all := []string{NsFull.String(), NsArchival.String(), NsLegacyArchival.String(), NsLegacyFull.String()}
+
+	tests := []struct {
+		name   string
+		names  []string
+		custom bool
+		want   []string
+		// err is a substring of the expected error, if any
+		err string
+	}{
+		{name: "full alias", names: []string{"full"}, want: []string{NsFull.String()}},
+		{name: "archival alias", names: []string{"archival"}, want: []string{NsArchival.String()}},
+		{name: "legacy-full alias", names: []string{"legacy-full"}, want: []string{NsLegacyFull.String()}},
+		{name: "legacy-archival alias", names: []string{"legacy-archival"}, want: []string{NsLegacyArchival.String()}},
+		{name: "all alias", names: []string{"all"}, want: all},
+		{name: "default network namespaces", names: DefaultNetworkNamespaces, want: all},
+		{name: "known key", names: []string{" /archival/v0.1.0 "}, want: []string{NsArchival.String()}},
+		{name: "network key", names: []string{"/custom/v0.1.0"}, want: []string{"/custom/v0.1.0"}},
+		{name: "unknown", names: []string{"ful"}, err: `unknown namespace "ful"`},
+		{name: "unknown among known", names: []string{"full", "/full/v9"}, err: `unknown namespace "/full/v9"`},
+		{name: "unknown custom", names: []string{"ful"}, custom: true, want: []string{"ful"}},
+		{name: "custom isn't aliased", names: []string{"full"}, custom: true, want: []string{"full"}},
+		{name: "empty", names: []string{"full", " "}, err: "empty namespace"},
+		{name: "none", names: nil, err: "no namespace given"},
+		{
+			name:  "deduplicated",
+			names: []string{"archival", "/archival/v0.1.0", "all", "archival"},
+			want:  []string{NsArchival.String(), NsFull.String(), NsLegacyArchival.String(), NsLegacyFull.String()},
+		},
+		{name: "deduplicated custom", names: []string{"a", "b", "a"}, custom: true, want: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := net.ResolveNamespaces(tt.names, tt.custom)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error with %q, got %v (%v)", tt.err, err, got)
+				}
+				// rejected names come with the list of the accepted ones
+				if tt.names != nil && !strings.Contains(err.Error(), "--is-custom") {
+					t.Errorf("expected the error to list the accepted namespaces, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
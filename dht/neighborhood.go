@@ -0,0 +1,254 @@
+package dht
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	kb "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxNeighborhoodRounds caps the FIND_NODE rounds of a neighborhood crawl in case the closest set never settles
+const maxNeighborhoodRounds = 32
+
+// NeighborhoodTrace records how the set of closest peers to a key converged
+type NeighborhoodTrace struct {
+	Namespace string
+	Rounds    []NeighborhoodRound
+	// Converged is false if the crawl stopped before the closest set settled
+	Converged bool
+	// Closest is the final set of KademliaK closest peers to the key
+	Closest []ClosestPeer
+}
+
+// NeighborhoodRound summarizes a single round of FIND_NODE queries
+type NeighborhoodRound struct {
+	Round   int
+	Queried int
+	Failed  int
+	// Discovered is the number of peers learned during the round that weren't known before
+	Discovered int
+	// Closest is the closest set to the key at the end of the round
+	Closest []ClosestPeer
+	// Changed is false once the round didn't modify the closest set
+	Changed bool
+}
+
+// RunNeighborhood crawls only the neighborhood of each of the given keys: it
+// repeatedly sends FIND_NODE for the key to the closest known peers until the
+// set of closest peers converges, and then asks that set for the providers of
+// the key. The returned CrawlResults only cover the peers that were queried, and
+// like Run, each call returns its own results.
+func (c *BaseCrawler) RunNeighborhood(ctx context.Context, startingNodes []*peer.AddrInfo, recordKeys []string) (*CrawlResults, []*NeighborhoodTrace) {
+	recordCids := make([]cid.Cid, len(recordKeys))
+	for i, recordKey := range recordKeys {
+		recordCid, err := KeyToCid(recordKey)
+		if err != nil {
+			return nil, nil
+		}
+		recordCids[i] = recordCid
+	}
+
+	// only the given context interrupts or stops the crawl
+	parent := ctx
+
+	// limit the overall duration of the crawl if there is a time budget
+	if c.opts.timeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeBudget)
+		defer cancel()
+	}
+
+	for _, ai := range startingNodes {
+		c.h.Peerstore().AddAddrs(ai.ID, ai.Addrs, peerstore.TempAddrTTL)
+	}
+
+	c.results = NewCrawlerResults()
+	c.results.start(c.h.ID(), recordKeys)
+	c.emit(CrawlStarted{Results: c.results})
+	found := newDiscoveries()
+	c.discover(found, "", startingNodes)
+	traces := make([]*NeighborhoodTrace, len(recordKeys))
+	visited := 0 // FIND_NODE queries sent by the walks, bounded by maxPeers
+	for i, recordKey := range recordKeys {
+		traces[i] = c.walkNeighborhood(ctx, found, &visited, startingNodes, recordKey, recordCids[i])
+	}
+	c.results.finish(crawlStatus(parent))
+	c.emit(CrawlFinished{Results: c.results, Duration: c.results.GetCrawlerDuration()})
+
+	return c.results, traces
+}
+
+func (c *BaseCrawler) walkNeighborhood(ctx context.Context, found *discoveries, visited *int, startingNodes []*peer.AddrInfo, recordKey string, recordCid cid.Cid) *NeighborhoodTrace {
+	target := kb.ConvertKey(string(recordCid.Hash()))
+	trace := &NeighborhoodTrace{Namespace: recordKey}
+
+	known := make(map[peer.ID]struct{})
+	queried := make(map[peer.ID]error) // nil if the FIND_NODE succeeded
+	for _, ai := range startingNodes {
+		known[ai.ID] = struct{}{}
+	}
+
+	closestSet := func() []peer.ID {
+		candidates := make([]peer.ID, 0, len(known))
+		for p := range known {
+			if err, done := queried[p]; done && err != nil {
+				continue // skip the peers that couldn't be reached
+			}
+			candidates = append(candidates, p)
+		}
+		sorted := kb.SortClosestPeers(candidates, target)
+		if len(sorted) > KademliaK {
+			sorted = sorted[:KademliaK]
+		}
+		return sorted
+	}
+
+	closest := closestSet()
+	for round := 1; round <= maxNeighborhoodRounds && ctx.Err() == nil; round++ {
+		toQuery := make([]peer.ID, 0)
+		for _, p := range closest {
+			if _, done := queried[p]; !done {
+				toQuery = append(toQuery, p)
+			}
+		}
+		if len(toQuery) == 0 {
+			trace.Converged = len(closest) > 0
+			break
+		}
+		// stop the walk once the max number of peers has been visited, keeping the closest ones
+		if c.opts.maxPeers > 0 {
+			left := c.opts.maxPeers - *visited
+			if left <= 0 {
+				break
+			}
+			if len(toQuery) > left {
+				toQuery = toQuery[:left]
+			}
+		}
+		*visited += len(toQuery)
+
+		var (
+			m      sync.Mutex
+			wg     sync.WaitGroup
+			failed int
+			newPs  int
+		)
+		sem := make(chan struct{}, c.opts.parallelism)
+		for _, p := range toQuery {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(p peer.ID) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				qctx, cancel := context.WithTimeout(ctx, c.opts.connectTimeout+c.opts.msgTimeout)
+				defer cancel()
+				closer, err := c.pm.GetClosestPeers(qctx, p, peer.ID(recordCid.Hash()))
+				if err != nil {
+					log.Tracef("peer: %s | namespace: %s | find-node error: %s\n", p.String(), recordKey, err.Error())
+					c.recordFailure(p, err)
+				} else {
+					for _, ai := range closer {
+						if ai.ID != c.h.ID() {
+							c.h.Peerstore().AddAddrs(ai.ID, ai.Addrs, peerstore.TempAddrTTL)
+						}
+					}
+					c.recordSuccess(found, p, closer)
+				}
+
+				m.Lock()
+				defer m.Unlock()
+				queried[p] = err
+				if err != nil {
+					failed++
+					return
+				}
+				for _, ai := range closer {
+					if ai.ID == c.h.ID() {
+						continue
+					}
+					if _, ok := known[ai.ID]; !ok {
+						known[ai.ID] = struct{}{}
+						newPs++
+					}
+				}
+			}(p)
+		}
+		wg.Wait()
+
+		next := closestSet()
+		changed := !samePeers(closest, next)
+		closest = next
+		trace.Rounds = append(trace.Rounds, NeighborhoodRound{
+			Round:      round,
+			Queried:    len(toQuery),
+			Failed:     failed,
+			Discovered: newPs,
+			Closest:    c.closestPeers(closest, target, queried),
+			Changed:    changed,
+		})
+		log.Debugf("neighborhood of %s | round %d | queried %d | failed %d | discovered %d\n", recordKey, round, len(toQuery), failed, newPs)
+	}
+
+	// ask the final neighborhood for the providers, unless the crawl is over, as every query would fail
+	for _, p := range closest {
+		if ctx.Err() != nil {
+			break
+		}
+		if err, done := queried[p]; done && err == nil {
+			c.queryProviders(ctx, p, []string{recordKey}, []cid.Cid{recordCid})
+		}
+	}
+
+	trace.Closest = c.closestPeers(closest, target, queried)
+	holders := make(map[peer.ID]struct{})
+	for _, provHolders := range c.results.GetProvHolders(recordKey) {
+		for _, holder := range provHolders {
+			holders[holder] = struct{}{}
+		}
+	}
+	for i := range trace.Closest {
+		_, trace.Closest[i].HoldsRecord = holders[trace.Closest[i].ID]
+	}
+	return trace
+}
+
+// closestPeers describes the given peers with the same statuses as the PlacementReport of a full crawl
+func (c *BaseCrawler) closestPeers(peers []peer.ID, target kb.ID, queried map[peer.ID]error) []ClosestPeer {
+	queryFailed := c.results.GetQueryFailedPeers()
+	closest := make([]ClosestPeer, len(peers))
+	for i, p := range peers {
+		status := PeerStatusUnvisited
+		if err, done := queried[p]; done {
+			status = PeerStatusSuccess
+			if err != nil {
+				status = PeerStatusFailed
+			} else if _, ok := queryFailed[p]; ok {
+				status = PeerStatusQueryFailed
+			}
+		}
+		closest[i] = ClosestPeer{
+			ID:     p,
+			CPL:    kb.CommonPrefixLen(kb.ConvertPeerID(p), target),
+			Status: status,
+		}
+	}
+	return closest
+}
+
+func samePeers(a, b []peer.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
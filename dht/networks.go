@@ -3,25 +3,26 @@ package dht
 import (
 	"fmt"
 
-	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 type Network string
 
-func NetworkFromString(net string) Network {
+// NetworkFromString returns the built-in network with the given name.
+// Use a NetworkRegistry to also resolve user-defined networks
+func NetworkFromString(net string) (Network, error) {
 	switch net {
 	case Mainnet.String():
-		return Mainnet
+		return Mainnet, nil
 	case Mocha.String():
-		return Mocha
+		return Mocha, nil
 	case Arabica.String():
-		return Arabica
+		return Arabica, nil
 	case Private.String():
-		return Private
+		return Private, nil
 	default:
-		return Mainnet
+		return "", fmt.Errorf("unknown network %q", net)
 	}
 }
 
@@ -58,6 +59,14 @@ const (
 	NsLegacyFull     NodeType = "full"
 )
 
+// NodeTypes lists every known NodeType
+var NodeTypes = []NodeType{
+	NsFull,
+	NsArchival,
+	NsLegacyArchival,
+	NsLegacyFull,
+}
+
 // NOTE: Every time we add a new long-running network, its bootstrap peers have to be added here.
 var BootstrapList = map[Network][]string{
 	Mainnet: {
@@ -86,27 +95,6 @@ var BootstrapList = map[Network][]string{
 	Private: {},
 }
 
-func BootstrapPeers(net Network) []peer.AddrInfo {
-	peers := make([]peer.AddrInfo, len(BootstrapList[net]))
-	for i, addr := range BootstrapList[net] {
-		peer, err := peer.AddrInfoFromString(addr)
-		if err != nil {
-			panic(err)
-		}
-		peers[i] = *peer
-	}
-	return peers
-}
-
-func LoadPrivKey() crypto.PrivKey {
-	// PeerID: 12D3KooWKyn4pnn6EZZVaxcexvCi7fgcfuvBnjnKXmcgnb8ptyGA
-	// nebula peer_id: 864
-	marshalledKey := []byte{231, 145, 17, 9, 50, 49, 35, 142, 193, 101, 216, 144, 202, 90, 29, 9, 115, 217, 85, 158, 241, 234, 36, 101, 128, 123, 72, 185, 107, 203, 43, 134, 150, 254, 30, 55, 24, 165, 176, 158, 88, 219, 161, 48, 38, 169, 151, 44, 171, 88, 212, 86, 158, 192, 33, 242, 10, 88, 197, 78, 233, 225, 88, 151}
-
-	privKey, err := crypto.UnmarshalEd25519PrivateKey(marshalledKey)
-	if err != nil {
-		panic(err)
-	}
-
-	return privKey
+func BootstrapPeers(net Network) ([]peer.AddrInfo, error) {
+	return ParseBootstrapAddrs(BootstrapList[net])
 }
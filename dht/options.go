@@ -0,0 +1,193 @@
+package dht
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// CrawlerOption is a functional option to tune the BaseCrawler
+type CrawlerOption func(*crawlerOptions) error
+
+type crawlerOptions struct {
+	parallelism    int
+	connectTimeout time.Duration
+	msgTimeout     time.Duration
+	maxPeers       int
+	timeBudget     time.Duration
+	retries        int
+	observers      []CrawlObserver
+}
+
+// defaultCrawlerOptions are always applied before the user-given options
+var defaultCrawlerOptions = func(o *crawlerOptions) error {
+	o.parallelism = DefaultCrawlParallelism
+	o.connectTimeout = DefaultCrawlConnectTimeout
+	o.msgTimeout = DefaultCrawlMsgTimeout
+	o.maxPeers = DefaultCrawlMaxPeers
+	o.timeBudget = DefaultCrawlTimeBudget
+	o.retries = DefaultCrawlRetries
+	return nil
+}
+
+// WithParallelism defines the number of peers that can be crawled in parallel
+func WithParallelism(parallelism int) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if parallelism <= 0 {
+			return fmt.Errorf("parallelism has to be greater than 0, got %d", parallelism)
+		}
+		o.parallelism = parallelism
+		return nil
+	}
+}
+
+// WithConnectTimeout defines the time for peer connection before timing out
+func WithConnectTimeout(timeout time.Duration) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("connect timeout has to be greater than 0, got %s", timeout)
+		}
+		o.connectTimeout = timeout
+		return nil
+	}
+}
+
+// WithMsgTimeout defines the amount of time a single DHT message is allowed to take before it's deemed failed
+func WithMsgTimeout(timeout time.Duration) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("message timeout has to be greater than 0, got %s", timeout)
+		}
+		o.msgTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxPeers stops the crawl once the given number of peers has been visited (0 means no limit).
+// In neighborhood mode it bounds the FIND_NODE queries of the walks of every namespace
+func WithMaxPeers(maxPeers int) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if maxPeers < 0 {
+			return fmt.Errorf("max peers can't be negative, got %d", maxPeers)
+		}
+		o.maxPeers = maxPeers
+		return nil
+	}
+}
+
+// WithTimeBudget defines the overall duration the crawl is allowed to take (0 means no limit)
+func WithTimeBudget(budget time.Duration) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if budget < 0 {
+			return fmt.Errorf("time budget can't be negative, got %s", budget)
+		}
+		o.timeBudget = budget
+		return nil
+	}
+}
+
+// WithRetries defines how many extra attempts are made for each failed provider query of a
+// crawled peer. The FIND_NODE requests that crawl the routing tables aren't retried
+func WithRetries(retries int) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if retries < 0 {
+			return fmt.Errorf("retries can't be negative, got %d", retries)
+		}
+		o.retries = retries
+		return nil
+	}
+}
+
+// WithObserver registers an observer of the crawl events (it can be given several times)
+func WithObserver(obs CrawlObserver) CrawlerOption {
+	return func(o *crawlerOptions) error {
+		if obs == nil {
+			return fmt.Errorf("observer can't be nil")
+		}
+		o.observers = append(o.observers, obs)
+		return nil
+	}
+}
+
+// ClientOption is a functional option to tune the Client
+type ClientOption func(*clientOptions) error
+
+type clientOptions struct {
+	privKey       crypto.PrivKey
+	userAgent     string
+	bootstrappers []peer.AddrInfo
+	hostOpts      []libp2p.Option
+	serverMode    bool
+	resolver      Resolver
+}
+
+// defaultClientOptions are always applied before the user-given options
+var defaultClientOptions = func(o *clientOptions) error {
+	o.userAgent = CustomUserAgent
+	return nil
+}
+
+// WithIdentity defines the key of the client host (an ephemeral one is generated by default)
+func WithIdentity(privKey crypto.PrivKey) ClientOption {
+	return func(o *clientOptions) error {
+		if privKey == nil {
+			return fmt.Errorf("identity can't be nil")
+		}
+		o.privKey = privKey
+		return nil
+	}
+}
+
+// WithUserAgent defines the agent version the client host announces over identify
+func WithUserAgent(userAgent string) ClientOption {
+	return func(o *clientOptions) error {
+		if userAgent == "" {
+			return fmt.Errorf("user agent can't be empty")
+		}
+		o.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithBootstrappers overrides the bootstrappers of the network
+func WithBootstrappers(bootstrappers []peer.AddrInfo) ClientOption {
+	return func(o *clientOptions) error {
+		if len(bootstrappers) == 0 {
+			return fmt.Errorf("at least one bootstrapper is needed")
+		}
+		o.bootstrappers = append([]peer.AddrInfo{}, bootstrappers...)
+		return nil
+	}
+}
+
+// WithHostOptions appends extra libp2p options to the ones the client host is created with
+func WithHostOptions(opts ...libp2p.Option) ClientOption {
+	return func(o *clientOptions) error {
+		o.hostOpts = append(o.hostOpts, opts...)
+		return nil
+	}
+}
+
+// WithServerMode runs the DHT node of the client as a server, which answers the queries of
+// the other peers and stores their records, e.g. to provide a namespace (a client by default)
+func WithServerMode() ClientOption {
+	return func(o *clientOptions) error {
+		o.serverMode = true
+		return nil
+	}
+}
+
+// WithResolver defines the resolver of the DNS addresses in the bootstrap checks (the system DNS
+// by default), e.g. a *madns.Resolver with a mock backend to run the checks offline
+func WithResolver(resolver Resolver) ClientOption {
+	return func(o *clientOptions) error {
+		if resolver == nil {
+			return fmt.Errorf("resolver can't be nil")
+		}
+		o.resolver = resolver
+		return nil
+	}
+}
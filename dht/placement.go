@@ -0,0 +1,123 @@
+package dht
+
+import (
+	kb "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// KademliaK is the number of peers closest to a key on which the provider
+// records are expected to be stored
+const KademliaK = 20
+
+// PlacementReport compares, for a single namespace, the peers on which
+// Kademlia should store the provider records with the peers that actually
+// returned them during the crawl
+type PlacementReport struct {
+	Namespace string
+	// Closest are the K discovered peers closest to the namespace's key, sorted by XOR distance
+	Closest []ClosestPeer
+	// FarHolders are the peers outside of the Closest set that still returned the record
+	FarHolders []ClosestPeer
+}
+
+// ClosestPeer describes a discovered peer in relation to the namespace's key
+type ClosestPeer struct {
+	ID peer.ID
+	// CPL is the common prefix length between the peer and the key in the Kademlia keyspace
+	CPL int
+	// Status is "success", "query_failed", "failed" or "unvisited"
+	Status      string
+	HoldsRecord bool
+}
+
+// Holders returns the number of Closest peers that returned the record
+func (r *PlacementReport) Holders() int {
+	holders := 0
+	for _, p := range r.Closest {
+		if p.HoldsRecord {
+			holders++
+		}
+	}
+	return holders
+}
+
+// Missing returns the Closest peers that didn't return the record
+func (r *PlacementReport) Missing() []ClosestPeer {
+	missing := make([]ClosestPeer, 0)
+	for _, p := range r.Closest {
+		if !p.HoldsRecord {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// PlacementReport computes the k closest peers to the given key out of every
+// discovered peer and checks which of them returned the provider records
+func (r *CrawlResults) PlacementReport(key string, k int) (*PlacementReport, error) {
+	recordCid, err := KeyToCid(key)
+	if err != nil {
+		return nil, err
+	}
+	target := kb.ConvertKey(string(recordCid.Hash()))
+
+	// gather every discovered peer along with its crawl status
+	statuses := make(map[peer.ID]string)
+	for p := range r.GetSuccPeers() {
+		statuses[p] = PeerStatusSuccess
+	}
+	for p := range r.GetQueryFailedPeers() {
+		statuses[p] = PeerStatusQueryFailed
+	}
+	for p := range r.GetFailedPeers() {
+		statuses[p] = PeerStatusFailed
+	}
+	for _, neighbors := range r.GetNeighbors() {
+		for _, p := range neighbors {
+			if _, ok := statuses[p]; !ok {
+				statuses[p] = PeerStatusUnvisited
+			}
+		}
+	}
+
+	// any peer that returned at least one provider for the key holds the record
+	holders := make(map[peer.ID]struct{})
+	for _, provHolders := range r.GetProvHolders(key) {
+		for _, holder := range provHolders {
+			holders[holder] = struct{}{}
+		}
+	}
+
+	discovered := make([]peer.ID, 0, len(statuses))
+	for p := range statuses {
+		discovered = append(discovered, p)
+	}
+	sorted := kb.SortClosestPeers(discovered, target)
+
+	closestPeer := func(p peer.ID) ClosestPeer {
+		_, holds := holders[p]
+		return ClosestPeer{
+			ID:          p,
+			CPL:         kb.CommonPrefixLen(kb.ConvertPeerID(p), target),
+			Status:      statuses[p],
+			HoldsRecord: holds,
+		}
+	}
+
+	report := &PlacementReport{Namespace: key}
+	closest := make(map[peer.ID]struct{}, k)
+	for i := 0; i < k && i < len(sorted); i++ {
+		closest[sorted[i]] = struct{}{}
+		report.Closest = append(report.Closest, closestPeer(sorted[i]))
+	}
+	for _, p := range sorted {
+		if _, ok := closest[p]; ok {
+			continue
+		}
+		if _, ok := holders[p]; ok {
+			report.FarHolders = append(report.FarHolders, closestPeer(p))
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,212 @@
+package dht
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"math/bits"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// xorDistance is the distance between the peer and the key in the Kademlia keyspace
+func xorDistance(p peer.ID, key []byte) []byte {
+	ph, kh := sha256.Sum256([]byte(p)), sha256.Sum256(key)
+	dist := make([]byte, len(ph))
+	for i := range ph {
+		dist[i] = ph[i] ^ kh[i]
+	}
+	return dist
+}
+
+// commonPrefixLen counts the leading zero bits of the distance
+func commonPrefixLen(dist []byte) int {
+	for i, b := range dist {
+		if b != 0 {
+			return i*8 + bits.LeadingZeros8(b)
+		}
+	}
+	return len(dist) * 8
+}
+
+func TestCrawlResultsPlacementReport(t *testing.T) {
+	ns := NsFull.String()
+	recordCid, err := KeyToCid(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte(recordCid.Hash())
+
+	succ := []peer.ID{"s0", "s1", "s2", "s3", "s4", "s5"}
+	failed := []peer.ID{"f0", "f1"}
+	unvisited := []peer.ID{"n0", "n1", "n2", "n3"}
+	provider := peer.ID("provider")
+
+	r := NewCrawlerResults()
+	r.start("crawler", []string{ns})
+	for _, p := range succ {
+		r.addSuccessfullPeer(p, PeerInfo{AddrInfo: peer.AddrInfo{ID: p}})
+	}
+	for _, p := range failed {
+		r.addFailedPeer(p, PeerInfo{AddrInfo: peer.AddrInfo{ID: p}}, CrawlFailure{Category: FailureDialTimeout})
+	}
+	// the neighbors that weren't crawled are only discovered, the crawled ones keep their status
+	neighbors := make([]*peer.AddrInfo, 0)
+	for _, p := range append(append([]peer.ID{succ[1], failed[0]}, unvisited...), unvisited[0]) {
+		neighbors = append(neighbors, &peer.AddrInfo{ID: p})
+	}
+	r.addNeighbors(succ[0], neighbors)
+	r.addQueryFailure(succ[2], ProviderQueryFailure{Namespace: ns, Error: "reset", Attempts: 1})
+
+	// expected order of every discovered peer by XOR distance to the key
+	discovered := slices.Concat(succ, failed, unvisited)
+	sort.Slice(discovered, func(i, j int) bool {
+		return bytes.Compare(xorDistance(discovered[i], key), xorDistance(discovered[j], key)) < 0
+	})
+	status := func(p peer.ID) string {
+		switch {
+		case p == succ[2]:
+			return PeerStatusQueryFailed
+		case strings.HasPrefix(string(p), "s"):
+			return PeerStatusSuccess
+		case strings.HasPrefix(string(p), "f"):
+			return PeerStatusFailed
+		default:
+			return PeerStatusUnvisited
+		}
+	}
+
+	// the closest and the farthest successful peers hold the record
+	const k = 5
+	holders := make(map[peer.ID]bool)
+	for _, p := range discovered {
+		if status(p) == PeerStatusSuccess {
+			holders[p] = true
+			break
+		}
+	}
+	farHolder := peer.ID("")
+	for i := len(discovered) - 1; i >= k; i-- {
+		if status(discovered[i]) == PeerStatusSuccess {
+			farHolder = discovered[i]
+			holders[farHolder] = true
+			break
+		}
+	}
+	if farHolder == "" {
+		t.Fatal("no successful peer outside of the closest ones")
+	}
+	for holder := range holders {
+		r.addProvider(ns, holder, provider, peer.AddrInfo{ID: provider})
+	}
+
+	tests := []struct {
+		name string
+		k    int
+		// closest is the number of expected Closest peers
+		closest    int
+		farHolders []peer.ID
+	}{
+		{name: "k closest", k: k, closest: k, farHolders: []peer.ID{farHolder}},
+		{name: "k above the discovered peers", k: KademliaK, closest: len(discovered)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := r.PlacementReport(ns, tt.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Namespace != ns || len(report.Closest) != tt.closest {
+				t.Fatalf("expected %d closest peers for %s, got %d for %s", tt.closest, ns, len(report.Closest), report.Namespace)
+			}
+
+			missing := make([]peer.ID, 0)
+			expectedHolders := 0
+			for i, got := range report.Closest {
+				p := discovered[i]
+				want := ClosestPeer{ID: p, CPL: commonPrefixLen(xorDistance(p, key)), Status: status(p), HoldsRecord: holders[p]}
+				if got != want {
+					t.Errorf("expected %+v as closest peer %d, got %+v", want, i, got)
+				}
+				if holders[p] {
+					expectedHolders++
+				} else {
+					missing = append(missing, p)
+				}
+			}
+			if report.Holders() != expectedHolders {
+				t.Errorf("expected %d holders among the closest peers, got %d", expectedHolders, report.Holders())
+			}
+			gotMissing := make([]peer.ID, 0)
+			for _, p := range report.Missing() {
+				gotMissing = append(gotMissing, p.ID)
+			}
+			if !slices.Equal(gotMissing, missing) {
+				t.Errorf("expected the missing peers %v, got %v", missing, gotMissing)
+			}
+
+			gotFar := make([]peer.ID, 0)
+			for _, p := range report.FarHolders {
+				if !p.HoldsRecord || p.Status != PeerStatusSuccess {
+					t.Errorf("unexpected far holder %+v", p)
+				}
+				gotFar = append(gotFar, p.ID)
+			}
+			if !slices.Equal(gotFar, tt.farHolders) {
+				t.Errorf("expected the far holders %v, got %v", tt.farHolders, gotFar)
+			}
+		})
+	}
+}
+
+func TestCrawlSnapshotNDJSONPlacement(t *testing.T) {
+	namespaces := []string{NsFull.String(), NsArchival.String()}
+	r := NewCrawlerResults()
+	r.start("crawler", namespaces)
+	r.addSuccessfullPeer("p0", PeerInfo{AddrInfo: peer.AddrInfo{ID: "p0"}})
+	r.addNeighbors("p0", []*peer.AddrInfo{{ID: "p1"}})
+	r.addProvider(namespaces[0], "p0", "provider", peer.AddrInfo{ID: "provider"})
+	r.finish(CrawlStatusComplete)
+
+	snapshot := r.Snapshot(Private)
+	var out bytes.Buffer
+	if err := snapshot.Write(&out, OutputNDJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	// the placement records carry the same data as the placement list of the JSON output
+	placement := make([]PlacementRecord, 0)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Type != RecordTypePlacement {
+			continue
+		}
+		var data PlacementRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		placement = append(placement, data)
+	}
+	if len(placement) != len(namespaces) {
+		t.Fatalf("expected a placement record per namespace, got %d", len(placement))
+	}
+	for i, record := range placement {
+		want := snapshot.Placement[i]
+		if record.Namespace != want.Namespace || record.Holders != want.Holders || len(record.Closest) != len(want.Closest) {
+			t.Errorf("expected the placement record %+v, got %+v", want, record)
+		}
+	}
+	if placement[0].Holders != 1 || len(placement[0].Closest) != 2 || placement[1].Holders != 0 {
+		t.Errorf("unexpected placement records %+v", placement)
+	}
+}
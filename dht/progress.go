@@ -0,0 +1,115 @@
+package dht
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// CrawlProgress is a point-in-time view of a running crawl
+type CrawlProgress struct {
+	Elapsed    time.Duration
+	Discovered int
+	Succeeded  int
+	Failed     int
+	// Providers is the number of distinct providers found, summed over the namespaces
+	Providers int
+	// Started and Finished are set once the crawl emitted CrawlStarted and CrawlFinished
+	Started  bool
+	Finished bool
+}
+
+// Visited returns the number of peers the crawl got an answer or an error from
+func (p CrawlProgress) Visited() int { return p.Succeeded + p.Failed }
+
+// Queued estimates the peers still to visit as the discovered peers that weren't visited yet.
+// In neighborhood mode most of the discovered peers are never visited, so it's an upper bound
+func (p CrawlProgress) Queued() int {
+	if queued := p.Discovered - p.Visited(); queued > 0 {
+		return queued
+	}
+	return 0
+}
+
+// PeersPerSecond returns the visit rate since the crawl started
+func (p CrawlProgress) PeersPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Visited()) / p.Elapsed.Seconds()
+}
+
+// ProgressTracker is a CrawlObserver that keeps the counters of a CrawlProgress up to date
+type ProgressTracker struct {
+	m          sync.Mutex
+	start      time.Time
+	duration   time.Duration
+	discovered int
+	succeeded  int
+	failed     int
+	providers  map[string]map[peer.ID]struct{}
+	finished   bool
+}
+
+// NewProgressTracker returns a tracker whose elapsed time starts with the crawl, so the
+// setup of the host and the bootstrap before it aren't counted
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{
+		providers: make(map[string]map[peer.ID]struct{}),
+	}
+}
+
+func (t *ProgressTracker) OnCrawlEvent(e CrawlEvent) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	switch e := e.(type) {
+	case CrawlStarted:
+		t.start = time.Now()
+	case PeerDiscovered:
+		t.discovered++
+	case PeerConnected:
+		t.succeeded++
+	case PeerFailed:
+		t.failed++
+	case ProvidersFound:
+		provs, ok := t.providers[e.Namespace]
+		if !ok {
+			provs = make(map[peer.ID]struct{})
+			t.providers[e.Namespace] = provs
+		}
+		for _, p := range e.Providers {
+			provs[p.ID] = struct{}{}
+		}
+	case CrawlFinished:
+		t.finished = true
+		if !t.start.IsZero() {
+			t.duration = time.Since(t.start)
+		}
+	}
+}
+
+// Progress returns the current state of the crawl
+func (t *ProgressTracker) Progress() CrawlProgress {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	p := CrawlProgress{
+		Discovered: t.discovered,
+		Succeeded:  t.succeeded,
+		Failed:     t.failed,
+		Started:    !t.start.IsZero(),
+		Finished:   t.finished,
+	}
+	switch {
+	case t.finished:
+		p.Elapsed = t.duration
+	case p.Started:
+		p.Elapsed = time.Since(t.start)
+	}
+	for _, provs := range t.providers {
+		p.Providers += len(provs)
+	}
+	return p
+}
@@ -0,0 +1,60 @@
+package dht
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestProgressTracker(t *testing.T) {
+	tracker := NewProgressTracker()
+	// the time before the crawl starts, like the bootstrap, isn't counted
+	time.Sleep(20 * time.Millisecond)
+	if p := tracker.Progress(); p.Started || p.Elapsed != 0 {
+		t.Errorf("unexpected progress before the crawl started: %+v", p)
+	}
+	tracker.OnCrawlEvent(CrawlStarted{})
+	if p := tracker.Progress(); !p.Started || p.Elapsed >= 20*time.Millisecond {
+		t.Errorf("expected the elapsed time to start with the crawl: %+v", p)
+	}
+
+	for _, p := range []peer.ID{"p0", "p1", "p2", "p3", "p4"} {
+		tracker.OnCrawlEvent(PeerDiscovered{Peer: p})
+	}
+	tracker.OnCrawlEvent(PeerConnected{Info: PeerInfo{AddrInfo: peer.AddrInfo{ID: "p0"}}})
+	tracker.OnCrawlEvent(PeerConnected{Info: PeerInfo{AddrInfo: peer.AddrInfo{ID: "p1"}}})
+	tracker.OnCrawlEvent(PeerFailed{Info: PeerInfo{AddrInfo: peer.AddrInfo{ID: "p2"}}})
+	// the same provider returned twice only counts once per namespace
+	tracker.OnCrawlEvent(ProvidersFound{Namespace: "full", Holder: "p0", Providers: []peer.AddrInfo{{ID: "a"}, {ID: "b"}}})
+	tracker.OnCrawlEvent(ProvidersFound{Namespace: "full", Holder: "p1", Providers: []peer.AddrInfo{{ID: "a"}}})
+	tracker.OnCrawlEvent(ProvidersFound{Namespace: "archival", Holder: "p1", Providers: []peer.AddrInfo{{ID: "a"}}})
+
+	p := tracker.Progress()
+	if p.Discovered != 5 || p.Succeeded != 2 || p.Failed != 1 || p.Visited() != 3 {
+		t.Errorf("unexpected peer counters: %+v", p)
+	}
+	if p.Providers != 3 {
+		t.Errorf("expected 3 providers, got %d", p.Providers)
+	}
+	if p.Queued() != 2 {
+		t.Errorf("expected 2 queued peers, got %d", p.Queued())
+	}
+	if p.Finished || p.Elapsed <= 0 || p.PeersPerSecond() <= 0 {
+		t.Errorf("unexpected progress of a running crawl: %+v", p)
+	}
+
+	tracker.OnCrawlEvent(CrawlFinished{})
+	finished := tracker.Progress()
+	time.Sleep(10 * time.Millisecond)
+	if again := tracker.Progress(); !finished.Finished || again.Elapsed != finished.Elapsed {
+		t.Errorf("expected the elapsed time to stop with the crawl: %s then %s", finished.Elapsed, again.Elapsed)
+	}
+
+	if queued := (CrawlProgress{Discovered: 1, Succeeded: 2}).Queued(); queued != 0 {
+		t.Errorf("expected the queue estimate to never be negative, got %d", queued)
+	}
+	if rate := (CrawlProgress{Succeeded: 2}).PeersPerSecond(); rate != 0 {
+		t.Errorf("expected no rate without elapsed time, got %f", rate)
+	}
+}
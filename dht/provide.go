@@ -0,0 +1,104 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kad "github.com/libp2p/go-libp2p-kad-dht"
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type VerifyMode string
+
+func (m VerifyMode) String() string { return string(m) }
+
+const (
+	// VerifyLookup looks up the peers closest to the namespace and asks them for the provider record
+	VerifyLookup VerifyMode = "lookup"
+	// VerifyCrawl crawls the network and asks every peer for the provider record
+	VerifyCrawl VerifyMode = "crawl"
+)
+
+func VerifyModeFromString(mode string) (VerifyMode, error) {
+	switch strings.ToLower(mode) {
+	case VerifyLookup.String():
+		return VerifyLookup, nil
+	case VerifyCrawl.String():
+		return VerifyCrawl, nil
+	default:
+		return "", fmt.Errorf("unknown verify mode: %q", mode)
+	}
+}
+
+// FindRecordHolders looks up the peers closest to the namespace and asks each of them, except the
+// provider itself (which always answers with its own record), whether it holds the provider's record.
+// It returns the peers holding the record and the number of peers that were asked
+func FindRecordHolders(ctx context.Context, dhtCli *kad.IpfsDHT, pm *pb.ProtocolMessenger, namespace string, provider peer.ID) ([]peer.ID, int, error) {
+	recordCid, err := KeyToCid(namespace)
+	if err != nil {
+		return nil, 0, err
+	}
+	closest, err := dhtCli.GetClosestPeers(ctx, string(recordCid.Hash()))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		m       sync.Mutex
+		holders = make([]peer.ID, 0)
+		asked   = 0
+	)
+	for _, p := range closest {
+		if p == provider {
+			continue
+		}
+		asked++
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			provs, _, err := pm.GetProviders(ctx, p, recordCid.Hash())
+			if err != nil {
+				return
+			}
+			for _, prov := range provs {
+				if prov.ID == provider {
+					m.Lock()
+					holders = append(holders, p)
+					m.Unlock()
+					return
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	return holders, asked, nil
+}
+
+// WaitForRecordHolders calls FindRecordHolders every interval until at least one peer holds the
+// provider's record, or until the context is done. It returns the holders and the number of attempts
+func WaitForRecordHolders(ctx context.Context, dhtCli *kad.IpfsDHT, pm *pb.ProtocolMessenger, namespace string, provider peer.ID, interval time.Duration) ([]peer.ID, int, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		attemptCtx, cancel := context.WithTimeout(ctx, interval)
+		holders, _, err := FindRecordHolders(attemptCtx, dhtCli, pm, namespace, provider)
+		cancel()
+		if err == nil && len(holders) > 0 {
+			return holders, attempt, nil
+		}
+
+		// wait for whatever is left of the interval
+		select {
+		case <-time.After(interval - time.Since(start)):
+		case <-ctx.Done():
+			if err == nil {
+				err = fmt.Errorf("no peer holds the record")
+			}
+			return nil, attempt, fmt.Errorf("provider %s not visible for %s: %w (%w)", provider, namespace, err, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package dht
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// NetworkConfig defines a Celestia network the tool can connect to
+type NetworkConfig struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// ProtocolPrefix is the prefix of the DHT protocol, e.g. /celestia/celestia
+	ProtocolPrefix string `json:"protocol_prefix" yaml:"protocol_prefix" toml:"protocol_prefix"`
+	// Bootstrappers are the multiaddrs (including the /p2p/ component) of the bootstrap peers
+	Bootstrappers []string `json:"bootstrappers" yaml:"bootstrappers" toml:"bootstrappers"`
+	// Namespaces are the DHT namespaces known to be used in the network. They are
+	// resolved like --namespace, so aliases such as "full" or "legacy-archival" are accepted
+	Namespaces []string `json:"namespaces" yaml:"namespaces" toml:"namespaces"`
+}
+
+func (n *NetworkConfig) Network() Network { return Network(n.Name) }
+
+func (n *NetworkConfig) KadPrefix() protocol.ID { return protocol.ID(n.ProtocolPrefix) }
+
+func (n *NetworkConfig) KadProtocol() protocol.ID {
+	return protocol.ID(fmt.Sprintf("%s/kad/1.0.0", n.ProtocolPrefix))
+}
+
+// BootstrapPeers parses the bootstrappers of the network
+func (n *NetworkConfig) BootstrapPeers() ([]peer.AddrInfo, error) {
+	peers, err := ParseBootstrapAddrs(n.Bootstrappers)
+	if err != nil {
+		return nil, fmt.Errorf("network %s: %w", n.Name, err)
+	}
+	return peers, nil
+}
+
+func (n *NetworkConfig) validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("network without name")
+	}
+	if !strings.HasPrefix(n.ProtocolPrefix, "/") {
+		return fmt.Errorf("invalid protocol prefix %q of network %s", n.ProtocolPrefix, n.Name)
+	}
+	if _, err := n.BootstrapPeers(); err != nil {
+		return err
+	}
+	if len(n.Namespaces) > 0 {
+		if _, err := n.ResolveNamespaces(n.Namespaces, false); err != nil {
+			return fmt.Errorf("network %s: %w", n.Name, err)
+		}
+	}
+	return nil
+}
+
+// NetworkRegistry holds the networks the tool knows about
+type NetworkRegistry struct {
+	networks map[string]*NetworkConfig
+}
+
+// DefaultRegistry returns a registry with the built-in networks
+func DefaultRegistry() *NetworkRegistry {
+	r := &NetworkRegistry{networks: make(map[string]*NetworkConfig)}
+	for _, net := range []Network{Mainnet, Mocha, Arabica, Private} {
+		r.networks[net.String()] = &NetworkConfig{
+			Name:           net.String(),
+			ProtocolPrefix: string(net.KadPrefix()),
+			Bootstrappers:  append([]string{}, BootstrapList[net]...),
+			Namespaces:     append([]string{}, DefaultNetworkNamespaces...),
+		}
+	}
+	return r
+}
+
+// registryFile is the layout of the network config files
+type registryFile struct {
+	Networks []*NetworkConfig `json:"networks" yaml:"networks" toml:"networks"`
+}
+
+// Load adds the networks defined in the given YAML, TOML or JSON file to the
+// registry. The format is picked from the file extension, and networks with
+// the name of an already registered one replace it.
+func (r *NetworkRegistry) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading networks config: %w", err)
+	}
+
+	var file registryFile
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		err = dec.Decode(&file)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(raw), &file)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown fields %v", md.Undecoded())
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&file)
+	default:
+		return fmt.Errorf("unsupported networks config format %q (use .yaml, .yml, .toml or .json)", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing networks config %s: %w", path, err)
+	}
+
+	for _, net := range file.Networks {
+		if err := net.validate(); err != nil {
+			return fmt.Errorf("networks config %s: %w", path, err)
+		}
+		if len(net.Namespaces) == 0 {
+			net.Namespaces = append([]string{}, DefaultNetworkNamespaces...)
+		}
+		r.networks[net.Name] = net
+	}
+	return nil
+}
+
+// Get returns the network with the given name, failing for unknown names
+func (r *NetworkRegistry) Get(name string) (*NetworkConfig, error) {
+	net, ok := r.networks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q (available: %s)", name, strings.Join(r.Names(), ", "))
+	}
+	return net, nil
+}
+
+// Names returns the sorted names of the registered networks
+func (r *NetworkRegistry) Names() []string {
+	return sortedKeys(r.networks)
+}
+
+// List returns the registered networks sorted by name
+func (r *NetworkRegistry) List() []*NetworkConfig {
+	nets := make([]*NetworkConfig, 0, len(r.networks))
+	for _, name := range r.Names() {
+		nets = append(nets, r.networks[name])
+	}
+	return nets
+}
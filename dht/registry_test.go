@@ -0,0 +1,193 @@
+package dht
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testBootstrapper = "/ip4/127.0.0.1/tcp/2121/p2p/12D3KooWSqZaLcn5Guypo2mrHr297YPJnV8KMEMXNjs3qAS8msw8"
+
+func TestNetworkRegistryLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		// network is the name of the network to check once loaded
+		network    string
+		prefix     string
+		namespaces []string
+		// err is a substring of the expected error, if any
+		err string
+	}{
+		{
+			name: "yaml",
+			file: "networks.yaml",
+			content: `networks:
+  - name: devnet
+    protocol_prefix: /celestia/devnet
+    bootstrappers: [` + testBootstrapper + `]
+    namespaces: [full, legacy-archival]
+`,
+			network:    "devnet",
+			prefix:     "/celestia/devnet",
+			namespaces: []string{"full", "legacy-archival"},
+		},
+		{
+			name: "yml without namespaces",
+			file: "networks.yml",
+			content: `networks:
+  - name: devnet
+    protocol_prefix: /celestia/devnet
+    bootstrappers: [` + testBootstrapper + `]
+`,
+			network:    "devnet",
+			prefix:     "/celestia/devnet",
+			namespaces: DefaultNetworkNamespaces,
+		},
+		{
+			name: "toml",
+			file: "networks.toml",
+			content: `[[networks]]
+name = "devnet"
+protocol_prefix = "/celestia/devnet"
+bootstrappers = ["` + testBootstrapper + `"]
+namespaces = ["archival"]
+`,
+			network:    "devnet",
+			prefix:     "/celestia/devnet",
+			namespaces: []string{"archival"},
+		},
+		{
+			name:       "json",
+			file:       "networks.json",
+			content:    `{"networks": [{"name": "devnet", "protocol_prefix": "/celestia/devnet", "bootstrappers": ["` + testBootstrapper + `"], "namespaces": ["/custom/v0.1.0"]}]}`,
+			network:    "devnet",
+			prefix:     "/celestia/devnet",
+			namespaces: []string{"/custom/v0.1.0"},
+		},
+		{
+			name: "built-in override",
+			file: "networks.yaml",
+			content: `networks:
+  - name: mocha
+    protocol_prefix: /celestia/mocha-5
+    bootstrappers: [` + testBootstrapper + `]
+`,
+			network:    "mocha",
+			prefix:     "/celestia/mocha-5",
+			namespaces: DefaultNetworkNamespaces,
+		},
+		{
+			name:    "yaml unknown field",
+			file:    "networks.yaml",
+			content: "networks:\n  - name: devnet\n    protocol_prefix: /celestia/devnet\n    bootstrapers: []\n",
+			err:     "field bootstrapers not found",
+		},
+		{
+			name:    "toml unknown field",
+			file:    "networks.toml",
+			content: "[[networks]]\nname = \"devnet\"\nprotocol_prefix = \"/celestia/devnet\"\nbootstrapers = []\n",
+			err:     "unknown fields",
+		},
+		{
+			name:    "json unknown field",
+			file:    "networks.json",
+			content: `{"networks": [{"name": "devnet", "protocol_prefix": "/celestia/devnet", "bootstrapers": []}]}`,
+			err:     `unknown field "bootstrapers"`,
+		},
+		{
+			name:    "malformed",
+			file:    "networks.json",
+			content: `{"networks": [`,
+			err:     "parsing networks config",
+		},
+		{
+			name:    "unsupported format",
+			file:    "networks.ini",
+			content: "[networks]\n",
+			err:     "unsupported networks config format",
+		},
+		{
+			name:    "missing name",
+			file:    "networks.yaml",
+			content: "networks:\n  - protocol_prefix: /celestia/devnet\n",
+			err:     "network without name",
+		},
+		{
+			name:    "invalid protocol prefix",
+			file:    "networks.yaml",
+			content: "networks:\n  - name: devnet\n    protocol_prefix: celestia/devnet\n",
+			err:     "invalid protocol prefix",
+		},
+		{
+			name:    "invalid bootstrapper",
+			file:    "networks.yaml",
+			content: "networks:\n  - name: devnet\n    protocol_prefix: /celestia/devnet\n    bootstrappers: [/ip4/127.0.0.1/tcp/2121]\n",
+			err:     "invalid bootstrap address",
+		},
+		{
+			name:    "empty namespace",
+			file:    "networks.yaml",
+			content: "networks:\n  - name: devnet\n    protocol_prefix: /celestia/devnet\n    namespaces: [full, \"\"]\n",
+			err:     "empty namespace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			r := DefaultRegistry()
+			err := r.Load(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error with %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			net, err := r.Get(tt.network)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if net.ProtocolPrefix != tt.prefix || !slices.Equal(net.Namespaces, tt.namespaces) {
+				t.Errorf("unexpected network %+v", net)
+			}
+			if peers, err := net.BootstrapPeers(); err != nil || len(peers) != 1 {
+				t.Errorf("expected a single bootstrapper, got %v (%v)", peers, err)
+			}
+			// the built-in networks are still there
+			if names := r.Names(); !slices.Contains(names, Mainnet.String()) || !slices.Contains(names, Private.String()) {
+				t.Errorf("expected the built-in networks next to the loaded one, got %v", names)
+			}
+		})
+	}
+
+	if err := DefaultRegistry().Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestNetworkRegistryGet(t *testing.T) {
+	r := DefaultRegistry()
+	for _, net := range []Network{Mainnet, Mocha, Arabica, Private} {
+		conf, err := r.Get(net.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.KadPrefix() != net.KadPrefix() {
+			t.Errorf("expected the prefix %s for %s, got %s", net.KadPrefix(), net, conf.KadPrefix())
+		}
+	}
+	if _, err := r.Get("unknown"); err == nil || !strings.Contains(err.Error(), "available") {
+		t.Errorf("expected an error listing the available networks, got %v", err)
+	}
+}
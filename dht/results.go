@@ -1,29 +1,110 @@
 package dht
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
+// PeerInfo gathers what we know about a crawled peer: its known multiaddrs
+// from the peerstore and the data it advertised over identify
+type PeerInfo struct {
+	peer.AddrInfo
+	AgentVersion    string
+	ProtocolVersion string
+	Protocols       []protocol.ID
+}
+
+// CrawlStatus tells whether the crawl behind some CrawlResults is over
+type CrawlStatus string
+
+func (s CrawlStatus) String() string { return string(s) }
+
+const (
+	// CrawlStatusInProgress is the status of the results of a running crawl
+	CrawlStatusInProgress CrawlStatus = "in_progress"
+	// CrawlStatusComplete is the status of a crawl that ran until the end, or until its time budget or max peers
+	CrawlStatusComplete CrawlStatus = "complete"
+	// CrawlStatusInterrupted is the status of a crawl cut short by ErrCrawlInterrupted or by the deadline
+	// of its context, leaving partial results
+	CrawlStatusInterrupted CrawlStatus = "interrupted"
+	// CrawlStatusStopped is the status of a crawl whose caller cancelled its context on purpose,
+	// e.g. an observer that found what it was looking for
+	CrawlStatusStopped CrawlStatus = "stopped"
+)
+
+// ErrCrawlInterrupted is the cause to cancel the context of a crawl with (see context.WithCancelCause)
+// when it's interrupted, e.g. by SIGINT or SIGTERM, rather than stopped on purpose
+var ErrCrawlInterrupted = errors.New("crawl interrupted")
+
+// crawlStatus returns the status of a crawl that ran with the given context
+func crawlStatus(ctx context.Context) CrawlStatus {
+	switch {
+	case ctx.Err() == nil:
+		return CrawlStatusComplete
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(context.Cause(ctx), ErrCrawlInterrupted):
+		return CrawlStatusInterrupted
+	default:
+		return CrawlStatusStopped
+	}
+}
+
 type CrawlResults struct {
 	m                sync.RWMutex
-	succPeers        map[peer.ID]peer.AddrInfo
-	failedPeers      map[peer.ID]peer.AddrInfo
-	provPeers        map[peer.ID]peer.AddrInfo
+	succPeers        map[peer.ID]PeerInfo
+	failedPeers      map[peer.ID]PeerInfo
+	failures         map[peer.ID]CrawlFailure
+	queryFailures    map[peer.ID][]ProviderQueryFailure
+	provPeers        map[string]map[peer.ID]peer.AddrInfo
+	provHolders      map[string]map[peer.ID]map[peer.ID]struct{}
 	agentVersionDist map[string]int
+	neighbors        map[peer.ID][]peer.ID
+	hostID           peer.ID
+	recordKeys       []string
 	initTime         time.Time
 	finishTime       time.Time
+	status           CrawlStatus
 }
 
 func NewCrawlerResults() *CrawlResults {
 	return &CrawlResults{
-		succPeers:        make(map[peer.ID]peer.AddrInfo),
-		failedPeers:      make(map[peer.ID]peer.AddrInfo),
-		provPeers:        make(map[peer.ID]peer.AddrInfo),
+		succPeers:        make(map[peer.ID]PeerInfo),
+		failedPeers:      make(map[peer.ID]PeerInfo),
+		failures:         make(map[peer.ID]CrawlFailure),
+		queryFailures:    make(map[peer.ID][]ProviderQueryFailure),
+		provPeers:        make(map[string]map[peer.ID]peer.AddrInfo),
+		provHolders:      make(map[string]map[peer.ID]map[peer.ID]struct{}),
 		agentVersionDist: make(map[string]int),
+		neighbors:        make(map[peer.ID][]peer.ID),
+		status:           CrawlStatusInProgress,
+	}
+}
+
+func (r *CrawlResults) start(hostID peer.ID, recordKeys []string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.hostID = hostID
+	r.recordKeys = append([]string{}, recordKeys...)
+	for _, key := range recordKeys {
+		if _, ok := r.provPeers[key]; !ok {
+			r.provPeers[key] = make(map[peer.ID]peer.AddrInfo)
+		}
 	}
+	r.initTime = time.Now()
+}
+
+// finish marks the crawl as over with the given status
+func (r *CrawlResults) finish(status CrawlStatus) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.finishTime = time.Now()
+	r.status = status
 }
 
 func (r *CrawlResults) addAgentVersion(av string) {
@@ -40,7 +121,8 @@ func (r *CrawlResults) addAgentVersion(av string) {
 	}
 }
 
-func (r *CrawlResults) addSuccessfullPeer(p peer.ID, ai peer.AddrInfo) {
+// addSuccessfullPeer returns true if the peer wasn't recorded as successful before
+func (r *CrawlResults) addSuccessfullPeer(p peer.ID, info PeerInfo) bool {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -48,23 +130,61 @@ func (r *CrawlResults) addSuccessfullPeer(p peer.ID, ai peer.AddrInfo) {
 	_, ok := r.succPeers[p]
 	if !ok {
 		// add it straight away
-		r.succPeers[p] = ai
+		r.succPeers[p] = info
 	}
+	return !ok
 }
 
-func (r *CrawlResults) addProvider(p peer.ID, ai peer.AddrInfo) {
+// addProvider records that the holder peer returned the given provider for the key
+func (r *CrawlResults) addProvider(key string, holder peer.ID, p peer.ID, ai peer.AddrInfo) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
+	provs, ok := r.provPeers[key]
+	if !ok {
+		provs = make(map[peer.ID]peer.AddrInfo)
+		r.provPeers[key] = provs
+	}
+
 	// if the peer wasn't already in the map, add it straight away
-	_, ok := r.provPeers[p]
+	_, ok = provs[p]
 	if !ok {
 		// add it straight away
-		r.provPeers[p] = ai
+		provs[p] = ai
+	}
+
+	// keep track of every peer holding the provider record
+	holders, ok := r.provHolders[key]
+	if !ok {
+		holders = make(map[peer.ID]map[peer.ID]struct{})
+		r.provHolders[key] = holders
 	}
+	if _, ok := holders[p]; !ok {
+		holders[p] = make(map[peer.ID]struct{})
+	}
+	holders[p][holder] = struct{}{}
+}
+
+func (r *CrawlResults) addQueryFailure(p peer.ID, failure ProviderQueryFailure) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.queryFailures[p] = append(r.queryFailures[p], failure)
 }
 
-func (r *CrawlResults) addFailedPeer(p peer.ID, ai peer.AddrInfo) {
+func (r *CrawlResults) addNeighbors(p peer.ID, rtPeers []*peer.AddrInfo) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	neighbors := make([]peer.ID, 0, len(rtPeers))
+	for _, ai := range rtPeers {
+		neighbors = append(neighbors, ai.ID)
+	}
+	r.neighbors[p] = neighbors
+}
+
+// addFailedPeer returns true if the peer wasn't recorded as failed before
+func (r *CrawlResults) addFailedPeer(p peer.ID, info PeerInfo, failure CrawlFailure) bool {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -72,16 +192,66 @@ func (r *CrawlResults) addFailedPeer(p peer.ID, ai peer.AddrInfo) {
 	_, ok := r.failedPeers[p]
 	if !ok {
 		// add it straight away
-		r.failedPeers[p] = ai
+		r.failedPeers[p] = info
+		r.failures[p] = failure
+	}
+	return !ok
+}
+
+// clone returns a deep copy of the results taken under a single lock, so that
+// the state read from the copy is consistent even while the crawl goes on
+func (r *CrawlResults) clone() *CrawlResults {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	c := NewCrawlerResults()
+	for k, v := range r.succPeers {
+		c.succPeers[k] = v
+	}
+	for k, v := range r.failedPeers {
+		c.failedPeers[k] = v
+	}
+	for k, v := range r.failures {
+		c.failures[k] = v
 	}
+	for k, v := range r.queryFailures {
+		c.queryFailures[k] = append([]ProviderQueryFailure{}, v...)
+	}
+	for key, provs := range r.provPeers {
+		c.provPeers[key] = make(map[peer.ID]peer.AddrInfo, len(provs))
+		for k, v := range provs {
+			c.provPeers[key][k] = v
+		}
+	}
+	for key, provs := range r.provHolders {
+		c.provHolders[key] = make(map[peer.ID]map[peer.ID]struct{}, len(provs))
+		for p, holders := range provs {
+			c.provHolders[key][p] = make(map[peer.ID]struct{}, len(holders))
+			for holder := range holders {
+				c.provHolders[key][p][holder] = struct{}{}
+			}
+		}
+	}
+	for k, v := range r.agentVersionDist {
+		c.agentVersionDist[k] = v
+	}
+	for k, v := range r.neighbors {
+		c.neighbors[k] = append([]peer.ID{}, v...)
+	}
+	c.hostID = r.hostID
+	c.recordKeys = append([]string{}, r.recordKeys...)
+	c.initTime = r.initTime
+	c.finishTime = r.finishTime
+	c.status = r.status
+	return c
 }
 
 // retrievals
-func (r *CrawlResults) GetSuccPeers() map[peer.ID]peer.AddrInfo {
+func (r *CrawlResults) GetSuccPeers() map[peer.ID]PeerInfo {
 	r.m.RLock()
 	defer r.m.RUnlock()
 
-	total := make(map[peer.ID]peer.AddrInfo)
+	total := make(map[peer.ID]PeerInfo)
 
 	for k, v := range r.succPeers {
 		total[k] = v
@@ -89,23 +259,70 @@ func (r *CrawlResults) GetSuccPeers() map[peer.ID]peer.AddrInfo {
 	return total
 }
 
-func (r *CrawlResults) GetProvPeers() map[peer.ID]peer.AddrInfo {
+// GetRecordKeys returns the keys (namespaces) that were crawled, in the requested order
+func (r *CrawlResults) GetRecordKeys() []string {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	return append([]string{}, r.recordKeys...)
+}
+
+// GetProvPeers returns the providers found for the given key
+func (r *CrawlResults) GetProvPeers(key string) map[peer.ID]peer.AddrInfo {
 	r.m.RLock()
 	defer r.m.RUnlock()
 
 	total := make(map[peer.ID]peer.AddrInfo)
 
-	for k, v := range r.provPeers {
+	for k, v := range r.provPeers[key] {
 		total[k] = v
 	}
 	return total
 }
 
-func (r *CrawlResults) GetFailedPeers() map[peer.ID]peer.AddrInfo {
+// GetProvHolders returns, for the given key, the crawled peers that returned each of the providers
+func (r *CrawlResults) GetProvHolders(key string) map[peer.ID][]peer.ID {
 	r.m.RLock()
 	defer r.m.RUnlock()
 
-	total := make(map[peer.ID]peer.AddrInfo)
+	total := make(map[peer.ID][]peer.ID, len(r.provHolders[key]))
+	for p, holders := range r.provHolders[key] {
+		for holder := range holders {
+			total[p] = append(total[p], holder)
+		}
+	}
+	return total
+}
+
+// GetReplicationFactors returns, for the given key, the number of crawled peers that returned each of the providers
+func (r *CrawlResults) GetReplicationFactors(key string) map[peer.ID]int {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	total := make(map[peer.ID]int, len(r.provHolders[key]))
+	for p, holders := range r.provHolders[key] {
+		total[p] = len(holders)
+	}
+	return total
+}
+
+// GetProvCounts returns the number of providers found for each of the crawled keys
+func (r *CrawlResults) GetProvCounts() map[string]int {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	counts := make(map[string]int, len(r.provPeers))
+	for key, provs := range r.provPeers {
+		counts[key] = len(provs)
+	}
+	return counts
+}
+
+func (r *CrawlResults) GetFailedPeers() map[peer.ID]PeerInfo {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	total := make(map[peer.ID]PeerInfo)
 
 	for k, v := range r.failedPeers {
 		total[k] = v
@@ -113,6 +330,74 @@ func (r *CrawlResults) GetFailedPeers() map[peer.ID]peer.AddrInfo {
 	return total
 }
 
+// GetFailures returns the classified error of each of the peers that couldn't be crawled
+func (r *CrawlResults) GetFailures() map[peer.ID]CrawlFailure {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	total := make(map[peer.ID]CrawlFailure, len(r.failures))
+	for k, v := range r.failures {
+		total[k] = v
+	}
+	return total
+}
+
+// GetQueryFailures returns the failed provider queries of each of the peers we could connect to
+func (r *CrawlResults) GetQueryFailures() map[peer.ID][]ProviderQueryFailure {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	total := make(map[peer.ID][]ProviderQueryFailure, len(r.queryFailures))
+	for k, v := range r.queryFailures {
+		total[k] = append([]ProviderQueryFailure{}, v...)
+	}
+	return total
+}
+
+// GetQueryFailedPeers returns the connected peers for which at least one provider query didn't recover
+func (r *CrawlResults) GetQueryFailedPeers() map[peer.ID]PeerInfo {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	total := make(map[peer.ID]PeerInfo)
+	for p, failures := range r.queryFailures {
+		for _, failure := range failures {
+			if !failure.Recovered {
+				total[p] = r.succPeers[p]
+				break
+			}
+		}
+	}
+	return total
+}
+
+func (r *CrawlResults) GetFailureDistributions() map[string]int {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	final := make(map[string]int)
+	total := 0
+
+	for _, failure := range r.failures {
+		final[failure.Category.String()]++
+		total++
+	}
+	final["total"] = total
+	return final
+}
+
+// GetNeighbors returns the routing table entries reported by each of the successfully crawled peers
+func (r *CrawlResults) GetNeighbors() map[peer.ID][]peer.ID {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	total := make(map[peer.ID][]peer.ID, len(r.neighbors))
+	for k, v := range r.neighbors {
+		total[k] = append([]peer.ID{}, v...)
+	}
+	return total
+}
+
 func (r *CrawlResults) GetAgentDistributions() map[string]int {
 	r.m.RLock()
 	defer r.m.RUnlock()
@@ -128,6 +413,24 @@ func (r *CrawlResults) GetAgentDistributions() map[string]int {
 	return final
 }
 
+// GetStatus returns whether the crawl is still running, complete, interrupted or stopped
+func (r *CrawlResults) GetStatus() CrawlStatus {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	return r.status
+}
+
+// GetCrawlerDuration returns the duration of the crawl, or the time since it started if it's still running
 func (c *CrawlResults) GetCrawlerDuration() time.Duration {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	if c.status == CrawlStatusInProgress {
+		if c.initTime.IsZero() {
+			return 0
+		}
+		return time.Since(c.initTime)
+	}
 	return c.finishTime.Sub(c.initTime)
 }
@@ -0,0 +1,228 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestCrawlResultsCounting(t *testing.T) {
+	r := NewCrawlerResults()
+	r.start("crawler", []string{"ns-a", "ns-b"})
+
+	peers := []peer.ID{"p0", "p1", "p2", "p3", "p4"}
+	agents := []string{"celestia-node/v0.20", "celestia-node/v0.20", "celestia-node/v0.21", "unknown"}
+	for i, p := range peers[:4] {
+		info := PeerInfo{AddrInfo: peer.AddrInfo{ID: p}, AgentVersion: agents[i]}
+		r.addSuccessfullPeer(p, info)
+		r.addAgentVersion(agents[i])
+	}
+	// a peer is only counted once, even if it's reported again
+	r.addSuccessfullPeer(peers[0], PeerInfo{AddrInfo: peer.AddrInfo{ID: peers[0]}})
+
+	r.addFailedPeer(peers[4], PeerInfo{AddrInfo: peer.AddrInfo{ID: peers[4]}}, CrawlFailure{Category: FailureDialTimeout, Error: "timeout"})
+	r.addFailedPeer(peers[4], PeerInfo{AddrInfo: peer.AddrInfo{ID: peers[4]}}, CrawlFailure{Category: FailureUnknown, Error: "again"})
+
+	// p0 and p1 hold the record of p2, p1 also holds its own one
+	r.addProvider("ns-a", peers[0], peers[2], peer.AddrInfo{ID: peers[2]})
+	r.addProvider("ns-a", peers[1], peers[2], peer.AddrInfo{ID: peers[2]})
+	r.addProvider("ns-a", peers[1], peers[2], peer.AddrInfo{ID: peers[2]})
+	r.addProvider("ns-a", peers[1], peers[1], peer.AddrInfo{ID: peers[1]})
+
+	r.addQueryFailure(peers[3], ProviderQueryFailure{Namespace: "ns-a", Error: "reset", Attempts: 2, Recovered: true})
+	r.addQueryFailure(peers[2], ProviderQueryFailure{Namespace: "ns-b", Error: "reset", Attempts: 1})
+	r.finish(CrawlStatusComplete)
+
+	if got := len(r.GetSuccPeers()); got != 4 {
+		t.Errorf("expected 4 successful peers, got %d", got)
+	}
+	if got := r.GetSuccPeers()[peers[0]].AgentVersion; got != agents[0] {
+		t.Errorf("expected the first report of a peer to be kept, got agent %q", got)
+	}
+	if failure := r.GetFailures()[peers[4]]; failure.Category != FailureDialTimeout {
+		t.Errorf("expected the first failure of a peer to be kept, got %+v", failure)
+	}
+
+	dist := r.GetAgentDistributions()
+	expectedDist := map[string]int{"celestia-node/v0.20": 2, "celestia-node/v0.21": 1, "unknown": 1, "total": 4}
+	if len(dist) != len(expectedDist) {
+		t.Errorf("expected agent distribution %v, got %v", expectedDist, dist)
+	}
+	for agent, count := range expectedDist {
+		if dist[agent] != count {
+			t.Errorf("expected %d peers with agent %s, got %d", count, agent, dist[agent])
+		}
+	}
+
+	failureDist := r.GetFailureDistributions()
+	if failureDist[FailureDialTimeout.String()] != 1 || failureDist["total"] != 1 {
+		t.Errorf("unexpected failure distribution %v", failureDist)
+	}
+
+	counts := r.GetProvCounts()
+	if counts["ns-a"] != 2 || counts["ns-b"] != 0 {
+		t.Errorf("unexpected provider counts %v", counts)
+	}
+	replication := r.GetReplicationFactors("ns-a")
+	if replication[peers[2]] != 2 || replication[peers[1]] != 1 {
+		t.Errorf("unexpected replication factors %v", replication)
+	}
+
+	// only the query that didn't recover marks its peer as failed
+	queryFailed := r.GetQueryFailedPeers()
+	if _, ok := queryFailed[peers[2]]; !ok || len(queryFailed) != 1 {
+		t.Errorf("expected only %s to have failed queries, got %v", peers[2], queryFailed)
+	}
+
+	meta := r.Snapshot(Private).Metadata
+	if meta.TotalPeers != 5 || meta.SuccPeers != 4 || meta.QueryFailedPeers != 1 || meta.FailPeers != 1 {
+		t.Errorf("unexpected snapshot counts %+v", meta)
+	}
+	if r.GetCrawlerDuration() < 0 {
+		t.Errorf("negative crawl duration %s", r.GetCrawlerDuration())
+	}
+}
+
+func TestCrawlResultsGettersReturnCopies(t *testing.T) {
+	r := NewCrawlerResults()
+	r.start("crawler", []string{"ns"})
+	r.addSuccessfullPeer("p0", PeerInfo{})
+	r.addNeighbors("p0", []*peer.AddrInfo{{ID: "p1"}})
+	r.addQueryFailure("p0", ProviderQueryFailure{Namespace: "ns", Error: errors.New("boom").Error(), Latency: time.Second})
+
+	delete(r.GetSuccPeers(), "p0")
+	r.GetNeighbors()["p0"][0] = "changed"
+	r.GetQueryFailures()["p0"][0].Error = "changed"
+	r.GetRecordKeys()[0] = "changed"
+
+	if _, ok := r.GetSuccPeers()["p0"]; !ok {
+		t.Error("successful peers were modified through a getter")
+	}
+	if r.GetNeighbors()["p0"][0] != "p1" {
+		t.Error("neighbors were modified through a getter")
+	}
+	if r.GetQueryFailures()["p0"][0].Error != "boom" {
+		t.Error("query failures were modified through a getter")
+	}
+	if r.GetRecordKeys()[0] != "ns" {
+		t.Error("record keys were modified through a getter")
+	}
+}
+
+func TestCrawlResultsStatus(t *testing.T) {
+	r := NewCrawlerResults()
+	r.start("crawler", []string{"ns"})
+	r.addSuccessfullPeer("p0", PeerInfo{AddrInfo: peer.AddrInfo{ID: "p0"}})
+
+	// a snapshot of a running crawl is marked as such and covers the crawl so far
+	time.Sleep(5 * time.Millisecond)
+	meta := r.Snapshot(Private).Metadata
+	if r.GetStatus() != CrawlStatusInProgress || meta.Status != CrawlStatusInProgress.String() {
+		t.Errorf("expected an in-progress crawl, got %s and %s", r.GetStatus(), meta.Status)
+	}
+	if meta.DurationMs <= 0 || meta.FinishedAt.Before(meta.StartedAt) {
+		t.Errorf("unexpected times of an in-progress snapshot: %+v", meta)
+	}
+	if r.GetCrawlerDuration() <= 0 {
+		t.Errorf("expected the duration of a running crawl to grow, got %s", r.GetCrawlerDuration())
+	}
+
+	r.finish(CrawlStatusInterrupted)
+	var csv strings.Builder
+	if err := r.Snapshot(Private).Write(&csv, OutputCSV); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(rows) != 2 || !strings.HasSuffix(rows[0], ",crawl_status") || !strings.HasSuffix(rows[1], ","+CrawlStatusInterrupted.String()) {
+		t.Errorf("expected the CSV rows to be marked as interrupted, got %q", rows)
+	}
+}
+
+func TestCrawlStatus(t *testing.T) {
+	cancelled := func(cause error) context.Context {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(cause)
+		return ctx
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want CrawlStatus
+	}{
+		{"running", context.Background(), CrawlStatusComplete},
+		{"deadline", expired, CrawlStatusInterrupted},
+		{"interrupted", cancelled(ErrCrawlInterrupted), CrawlStatusInterrupted},
+		{"wrapped interruption", cancelled(fmt.Errorf("SIGTERM: %w", ErrCrawlInterrupted)), CrawlStatusInterrupted},
+		{"cancelled", cancelled(nil), CrawlStatusStopped},
+		{"other cause", cancelled(errors.New("providers found")), CrawlStatusStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crawlStatus(tt.ctx); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCrawlResultsSnapshotConsistent(t *testing.T) {
+	r := NewCrawlerResults()
+	r.start("crawler", []string{"ns"})
+
+	// every peer is recorded before the providers it returns, so a consistent
+	// snapshot never has a holder that isn't among its peers
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			p := peer.ID(fmt.Sprintf("p%d", i))
+			r.addSuccessfullPeer(p, PeerInfo{AddrInfo: peer.AddrInfo{ID: p}})
+			r.addAgentVersion("celestia-node")
+			r.addProvider("ns", p, p, peer.AddrInfo{ID: p})
+			if i%3 == 0 {
+				r.addQueryFailure(p, ProviderQueryFailure{Namespace: "ns", Error: "reset", Attempts: 1})
+			}
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		s := r.Snapshot(Private)
+		peers := make(map[string]string, len(s.Peers))
+		queryFailed := 0
+		for _, p := range s.Peers {
+			peers[p.PeerID] = p.Status
+			if p.Status == PeerStatusQueryFailed {
+				queryFailed++
+			}
+		}
+		if s.Metadata.TotalPeers != len(s.Peers) || s.Metadata.QueryFailedPeers != queryFailed {
+			t.Fatalf("metadata %+v doesn't match the %d peers (%d query failed)", s.Metadata, len(s.Peers), queryFailed)
+		}
+		if s.Metadata.Providers["ns"] != len(s.Providers) || s.AgentVersions["celestia-node"] != len(s.Peers) {
+			t.Fatalf("metadata %+v doesn't match the %d providers and agents %v", s.Metadata, len(s.Providers), s.AgentVersions)
+		}
+		for _, prov := range s.Providers {
+			for _, holder := range prov.Holders {
+				if _, ok := peers[holder]; !ok {
+					t.Fatalf("holder %s of the snapshot isn't among its peers", holder)
+				}
+			}
+		}
+		if len(s.Placement) != 1 || s.Placement[0].Holders != min(len(s.Peers), KademliaK) {
+			t.Fatalf("placement %+v doesn't match the %d peers", s.Placement, len(s.Peers))
+		}
+	}
+}
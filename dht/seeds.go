@@ -0,0 +1,222 @@
+package dht
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ParseBootstrapAddrs parses the given /p2p/ multiaddrs, merging the ones of the
+// same peer. Every invalid address is reported in the returned error
+func ParseBootstrapAddrs(addrs []string) ([]peer.AddrInfo, error) {
+	var errs []error
+	maddrs := make([]ma.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		maddr, err := ma.NewMultiaddr(strings.TrimSpace(addr))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid bootstrap address %q: %w", addr, err))
+			continue
+		}
+		if _, err := peer.AddrInfoFromP2pAddr(maddr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid bootstrap address %q: %w", addr, err))
+			continue
+		}
+		maddrs = append(maddrs, maddr)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	peers, err := peer.AddrInfosFromP2pAddrs(maddrs...)
+	if err != nil {
+		return nil, err
+	}
+	// the same address may be listed more than once, like in the crawl exports
+	for i := range peers {
+		peers[i].Addrs = ma.Unique(peers[i].Addrs)
+	}
+	return peers, nil
+}
+
+// LoadSeedFile reads the peers to start a crawl or lookup from. The file can
+// be a previous crawl export (.json, .ndjson or .csv, see CrawlSnapshot), or a
+// plain text file with one /p2p/ multiaddr per line, where empty lines and
+// lines starting with "#" are ignored. Peers without addresses are skipped.
+func LoadSeedFile(path string) ([]peer.AddrInfo, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading seed file: %w", err)
+	}
+
+	var seeds []peer.AddrInfo
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		seeds, err = seedsFromJSON(raw)
+	case ".ndjson":
+		seeds, err = seedsFromNDJSON(raw)
+	case ".csv":
+		seeds, err = seedsFromCSV(raw)
+	default:
+		seeds, err = seedsFromText(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("seed file %s: %w", path, err)
+	}
+	return seeds, nil
+}
+
+func seedsFromJSON(raw []byte) ([]peer.AddrInfo, error) {
+	var snapshot CrawlSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, err
+	}
+
+	records := make([]seedRecord, 0, len(snapshot.Peers)+len(snapshot.Providers))
+	for _, p := range snapshot.Peers {
+		records = append(records, seedRecord{PeerID: p.PeerID, Addrs: p.Addrs})
+	}
+	for _, p := range snapshot.Providers {
+		records = append(records, seedRecord{PeerID: p.PeerID, Addrs: p.Addrs})
+	}
+	return seedsFromRecords(records)
+}
+
+func seedsFromNDJSON(raw []byte) ([]peer.AddrInfo, error) {
+	records := make([]seedRecord, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record struct {
+			Type string     `json:"type"`
+			Data seedRecord `json:"data"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.Type == RecordTypePeer || record.Type == RecordTypeProvider {
+			records = append(records, record.Data)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return seedsFromRecords(records)
+}
+
+func seedsFromCSV(raw []byte) ([]peer.AddrInfo, error) {
+	rows, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	// find the columns by name, as they are listed in the header
+	peerIDCol, addrsCol := -1, -1
+	for i, col := range rows[0] {
+		switch col {
+		case "peer_id":
+			peerIDCol = i
+		case "addrs":
+			addrsCol = i
+		}
+	}
+	if peerIDCol < 0 || addrsCol < 0 {
+		return nil, fmt.Errorf("missing peer_id or addrs columns")
+	}
+
+	records := make([]seedRecord, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := seedRecord{PeerID: row[peerIDCol]}
+		if row[addrsCol] != "" {
+			record.Addrs = strings.Split(row[addrsCol], ";")
+		}
+		records = append(records, record)
+	}
+	return seedsFromRecords(records)
+}
+
+func seedsFromText(raw []byte) ([]peer.AddrInfo, error) {
+	addrs := make([]string, 0)
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	return ParseBootstrapAddrs(addrs)
+}
+
+// seedRecord holds the fields of the crawl exports that are needed to seed a crawl
+type seedRecord struct {
+	PeerID string   `json:"peer_id"`
+	Addrs  []string `json:"addrs"`
+}
+
+func seedsFromRecords(records []seedRecord) ([]peer.AddrInfo, error) {
+	var errs []error
+	seen := make(map[peer.ID]int)
+	seeds := make([]peer.AddrInfo, 0, len(records))
+	for _, record := range records {
+		if len(record.Addrs) == 0 {
+			continue
+		}
+		pid, err := peer.Decode(record.PeerID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid peer ID %q: %w", record.PeerID, err))
+			continue
+		}
+
+		ai := peer.AddrInfo{ID: pid}
+		for _, addr := range record.Addrs {
+			maddr, err := ma.NewMultiaddr(addr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid address %q of peer %s: %w", addr, record.PeerID, err))
+				continue
+			}
+			ai.Addrs = append(ai.Addrs, maddr)
+		}
+
+		// merge the addresses of the peers listed more than once
+		if idx, ok := seen[pid]; ok {
+			seeds[idx].Addrs = ma.Unique(append(seeds[idx].Addrs, ai.Addrs...))
+			continue
+		}
+		seen[pid] = len(seeds)
+		seeds = append(seeds, ai)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return seeds, nil
+}
+
+// MergeAddrInfos merges the given lists of peers, combining the addresses of repeated peers
+func MergeAddrInfos(lists ...[]peer.AddrInfo) []peer.AddrInfo {
+	seen := make(map[peer.ID]int)
+	merged := make([]peer.AddrInfo, 0)
+	for _, list := range lists {
+		for _, ai := range list {
+			if idx, ok := seen[ai.ID]; ok {
+				merged[idx].Addrs = ma.Unique(append(merged[idx].Addrs, ai.Addrs...))
+				continue
+			}
+			seen[ai.ID] = len(merged)
+			merged = append(merged, peer.AddrInfo{ID: ai.ID, Addrs: append([]ma.Multiaddr{}, ai.Addrs...)})
+		}
+	}
+	return merged
+}
@@ -0,0 +1,197 @@
+package dht
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const (
+	seedPeerA = "12D3KooWSqZaLcn5Guypo2mrHr297YPJnV8KMEMXNjs3qAS8msw8"
+	seedPeerB = "12D3KooWNDPmjxZFbDwvesaqWhZvcP5xDeyVzyaNfc4PyVC89A9j"
+	seedPeerC = "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+)
+
+// seedAddrs flattens the seeds into the addresses of each peer ID, for comparison
+func seedAddrs(seeds []peer.AddrInfo) map[string][]string {
+	addrs := make(map[string][]string, len(seeds))
+	for _, ai := range seeds {
+		for _, addr := range ai.Addrs {
+			addrs[ai.ID.String()] = append(addrs[ai.ID.String()], addr.String())
+		}
+		slices.Sort(addrs[ai.ID.String()])
+	}
+	return addrs
+}
+
+func TestLoadSeedFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string][]string
+		// err is a substring of the expected error, if any
+		err string
+	}{
+		{
+			name: "text",
+			file: "seeds.txt",
+			content: `# devnet nodes
+
+/ip4/127.0.0.1/tcp/4001/p2p/` + seedPeerA + `
+   /ip4/127.0.0.1/tcp/4002/p2p/` + seedPeerB + `
+  # a second address of the first node
+/ip4/127.0.0.1/udp/4001/quic-v1/p2p/` + seedPeerA + `
+/ip4/127.0.0.1/tcp/4001/p2p/` + seedPeerA + `
+/dnsaddr/bootstrap.libp2p.io/p2p/` + seedPeerC + `
+`,
+			want: map[string][]string{
+				seedPeerA: {"/ip4/127.0.0.1/tcp/4001", "/ip4/127.0.0.1/udp/4001/quic-v1"},
+				seedPeerB: {"/ip4/127.0.0.1/tcp/4002"},
+				// dnsaddrs are resolved when dialing, not when loading
+				seedPeerC: {"/dnsaddr/bootstrap.libp2p.io"},
+			},
+		},
+		{
+			name:    "only comments",
+			file:    "seeds",
+			content: "# nothing yet\n\n",
+			want:    map[string][]string{},
+		},
+		{
+			name:    "text invalid multiaddr",
+			file:    "seeds.txt",
+			content: "/ip4/127.0.0.1/tcp/4001/p2p/" + seedPeerA + "\n/ip4/300.0.0.1/tcp/4001/p2p/" + seedPeerB + "\n",
+			err:     `invalid bootstrap address "/ip4/300.0.0.1/tcp/4001/p2p/` + seedPeerB + `"`,
+		},
+		{
+			name:    "text without peer ID",
+			file:    "seeds.txt",
+			content: "/ip4/127.0.0.1/tcp/4001\n",
+			err:     "invalid bootstrap address",
+		},
+		{
+			name: "json",
+			file: "crawl.json",
+			content: `{"metadata": {"network": "private"}, "peers": [
+				{"peer_id": "` + seedPeerA + `", "addrs": ["/ip4/127.0.0.1/tcp/4001"]},
+				{"peer_id": "` + seedPeerB + `", "addrs": []}
+			], "providers": [
+				{"peer_id": "` + seedPeerA + `", "addrs": ["/ip4/127.0.0.1/tcp/4001", "/ip4/10.0.0.1/tcp/4001"]},
+				{"peer_id": "` + seedPeerC + `", "addrs": ["/dnsaddr/bootstrap.libp2p.io"]}
+			]}`,
+			want: map[string][]string{
+				seedPeerA: {"/ip4/10.0.0.1/tcp/4001", "/ip4/127.0.0.1/tcp/4001"},
+				seedPeerC: {"/dnsaddr/bootstrap.libp2p.io"},
+			},
+		},
+		{
+			name:    "json invalid peer ID",
+			file:    "crawl.json",
+			content: `{"peers": [{"peer_id": "not-a-peer", "addrs": ["/ip4/127.0.0.1/tcp/4001"]}]}`,
+			err:     `invalid peer ID "not-a-peer"`,
+		},
+		{
+			name:    "json invalid multiaddr",
+			file:    "crawl.json",
+			content: `{"peers": [{"peer_id": "` + seedPeerA + `", "addrs": ["/ip4/127.0.0.1/tcp/4001", "/tcp/nope"]}]}`,
+			err:     `invalid address "/tcp/nope"`,
+		},
+		{
+			name: "ndjson",
+			file: "crawl.ndjson",
+			content: `{"version": 4, "type": "metadata", "data": {"network": "private"}}
+{"version": 4, "type": "peer", "data": {"peer_id": "` + seedPeerA + `", "addrs": ["/ip4/127.0.0.1/tcp/4001"]}}
+
+{"version": 4, "type": "agent_version", "data": {"agent_version": "celestia-node", "count": 1}}
+{"version": 4, "type": "provider", "data": {"peer_id": "` + seedPeerB + `", "addrs": ["/ip4/127.0.0.1/tcp/4002"]}}
+`,
+			want: map[string][]string{
+				seedPeerA: {"/ip4/127.0.0.1/tcp/4001"},
+				seedPeerB: {"/ip4/127.0.0.1/tcp/4002"},
+			},
+		},
+		{
+			name:    "ndjson malformed line",
+			file:    "crawl.ndjson",
+			content: `{"version": 4, "type": "metadata", "data": {}}` + "\n{\"type\": \"peer\",\n",
+			err:     "line 2",
+		},
+		{
+			name: "csv",
+			file: "crawl.csv",
+			content: `version,kind,namespace,peer_id,status,addrs
+4,peer,,` + seedPeerA + `,success,/ip4/127.0.0.1/tcp/4001;/ip4/127.0.0.1/udp/4001/quic-v1
+4,peer,,` + seedPeerB + `,failed,
+4,provider,/full/v0.1.0,` + seedPeerA + `,,/ip4/127.0.0.1/tcp/4001
+`,
+			want: map[string][]string{
+				seedPeerA: {"/ip4/127.0.0.1/tcp/4001", "/ip4/127.0.0.1/udp/4001/quic-v1"},
+			},
+		},
+		{
+			name:    "csv without addrs",
+			file:    "crawl.csv",
+			content: "version,kind,peer_id\n4,peer," + seedPeerA + "\n",
+			err:     "missing peer_id or addrs columns",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			seeds, err := LoadSeedFile(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error with %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// every peer is listed once
+			if got := seedAddrs(seeds); len(got) != len(seeds) || len(got) != len(tt.want) {
+				t.Errorf("expected %d seeds, got %v", len(tt.want), seeds)
+			}
+			for id, want := range tt.want {
+				if got := seedAddrs(seeds)[id]; !slices.Equal(got, want) {
+					t.Errorf("expected the addresses %v for %s, got %v", want, id, got)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadSeedFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing seed file")
+	}
+}
+
+func TestMergeAddrInfos(t *testing.T) {
+	a, b := peer.ID(seedPeerA), peer.ID(seedPeerB)
+	tcp, quic := ma.StringCast("/ip4/127.0.0.1/tcp/4001"), ma.StringCast("/ip4/127.0.0.1/udp/4001/quic-v1")
+
+	bootstrappers := []peer.AddrInfo{{ID: a, Addrs: []ma.Multiaddr{tcp}}}
+	seeds := []peer.AddrInfo{{ID: b, Addrs: []ma.Multiaddr{tcp}}, {ID: a, Addrs: []ma.Multiaddr{tcp, quic}}}
+	merged := MergeAddrInfos(bootstrappers, seeds)
+
+	if len(merged) != 2 || merged[0].ID != a || merged[1].ID != b {
+		t.Fatalf("expected the bootstrappers first and each peer once, got %v", merged)
+	}
+	if len(merged[0].Addrs) != 2 {
+		t.Errorf("expected the addresses of the repeated peer to be combined, got %v", merged[0].Addrs)
+	}
+	// the given lists are left untouched
+	if len(bootstrappers[0].Addrs) != 1 {
+		t.Errorf("the bootstrappers were modified: %v", bootstrappers)
+	}
+}
@@ -0,0 +1,277 @@
+package dht
+
+import (
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SnapshotVersion is the version of the CrawlSnapshot schema.
+// It has to be increased on every non backwards compatible change of the schema.
+const SnapshotVersion = 4
+
+// Peer status values used in PeerRecord.Status
+const (
+	PeerStatusSuccess = "success"
+	// PeerStatusQueryFailed is used for peers we could connect to, but for
+	// which at least one provider query failed even after the retries
+	PeerStatusQueryFailed = "query_failed"
+	PeerStatusFailed      = "failed"
+)
+
+// CrawlSnapshot is the serializable representation of the CrawlResults
+type CrawlSnapshot struct {
+	// Version of the schema of the snapshot (see SnapshotVersion)
+	Version int `json:"version"`
+	// Metadata describes the crawl run that produced the snapshot
+	Metadata CrawlMetadata `json:"metadata"`
+	// Peers lists every peer visited by the crawler, reachable or not
+	Peers []PeerRecord `json:"peers"`
+	// Providers lists every peer reported as provider of each of the crawled namespaces
+	Providers []ProviderRecord `json:"providers"`
+	// AgentVersions maps each agent version to the number of reachable peers using it
+	AgentVersions map[string]int `json:"agent_versions"`
+	// FailureCategories maps each FailureCategory to the number of unreachable peers that hit it
+	FailureCategories map[string]int `json:"failure_categories"`
+	// Placement compares, for each namespace, the KademliaK closest peers to its key with the actual holders
+	Placement []PlacementRecord `json:"placement"`
+}
+
+// CrawlMetadata describes a single crawl run
+type CrawlMetadata struct {
+	Network    string   `json:"network"`
+	Namespaces []string `json:"namespaces"`
+	HostID     string   `json:"host_id"`
+	// Status is "complete", "interrupted", "stopped" or "in_progress" (see CrawlStatus). Only
+	// complete snapshots cover the whole crawl, the others hold partial results
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is the time the snapshot was taken while the crawl is in progress
+	FinishedAt time.Time `json:"finished_at"`
+	// DurationMs is the duration of the crawl in milliseconds
+	DurationMs int64 `json:"duration_ms"`
+	TotalPeers int   `json:"total_peers"`
+	// SuccPeers counts every peer we could connect to, including the QueryFailedPeers
+	SuccPeers        int `json:"successful_peers"`
+	QueryFailedPeers int `json:"query_failed_peers"`
+	FailPeers        int `json:"failed_peers"`
+	// Providers maps each crawled namespace to its number of providers
+	Providers map[string]int `json:"providers"`
+}
+
+// PeerRecord holds the crawled information of a single peer
+type PeerRecord struct {
+	PeerID string `json:"peer_id"`
+	// Status is either "success", "query_failed" or "failed"
+	Status          string   `json:"status"`
+	Addrs           []string `json:"addrs"`
+	AgentVersion    string   `json:"agent_version,omitempty"`
+	ProtocolVersion string   `json:"protocol_version,omitempty"`
+	Protocols       []string `json:"protocols,omitempty"`
+	// FailureCategory and Error are only set for failed peers (see FailureCategory)
+	FailureCategory string `json:"failure_category,omitempty"`
+	Error           string `json:"error,omitempty"`
+	// ProviderQueryFailures lists the provider queries that failed at least once
+	ProviderQueryFailures []ProviderQueryRecord `json:"provider_query_failures,omitempty"`
+}
+
+// ProviderQueryRecord holds a provider query that failed at least once against a connected peer
+type ProviderQueryRecord struct {
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+	// LatencyMs is the total time spent on the query in milliseconds, including the retries
+	LatencyMs int64 `json:"latency_ms"`
+	Attempts  int   `json:"attempts"`
+	// Recovered is true if one of the retries succeeded
+	Recovered bool `json:"recovered"`
+}
+
+// ProviderRecord holds a peer that was reported as provider of a namespace
+type ProviderRecord struct {
+	Namespace string   `json:"namespace"`
+	PeerID    string   `json:"peer_id"`
+	Addrs     []string `json:"addrs"`
+	// Holders lists the crawled peers that returned the provider record
+	Holders []string `json:"holders"`
+	// ReplicationFactor is the number of Holders
+	ReplicationFactor int `json:"replication_factor"`
+}
+
+// PlacementRecord is the serializable version of a PlacementReport
+type PlacementRecord struct {
+	Namespace string `json:"namespace"`
+	// Holders is the number of Closest peers that returned the record
+	Holders    int                 `json:"holders"`
+	Closest    []ClosestPeerRecord `json:"closest"`
+	FarHolders []ClosestPeerRecord `json:"far_holders"`
+}
+
+type ClosestPeerRecord struct {
+	PeerID string `json:"peer_id"`
+	// CPL is the common prefix length with the namespace's key in the Kademlia keyspace
+	CPL         int    `json:"cpl"`
+	Status      string `json:"status"`
+	HoldsRecord bool   `json:"holds_record"`
+}
+
+// Snapshot composes a serializable copy of the current state of the results. It is
+// built out of a single copy of the results, so it's consistent even during the crawl
+func (r *CrawlResults) Snapshot(net Network) *CrawlSnapshot {
+	r = r.clone()
+	succPeers := r.GetSuccPeers()
+	failedPeers := r.GetFailedPeers()
+	failures := r.GetFailures()
+	queryFailures := r.GetQueryFailures()
+	queryFailedPeers := r.GetQueryFailedPeers()
+	recordKeys := r.GetRecordKeys()
+
+	providers := make([]ProviderRecord, 0)
+	provCounts := make(map[string]int, len(recordKeys))
+	for _, key := range recordKeys {
+		provPeers := r.GetProvPeers(key)
+		provHolders := r.GetProvHolders(key)
+		provCounts[key] = len(provPeers)
+
+		keyProviders := make([]ProviderRecord, 0, len(provPeers))
+		for p, ai := range provPeers {
+			holders := make([]string, len(provHolders[p]))
+			for i, holder := range provHolders[p] {
+				holders[i] = holder.String()
+			}
+			sort.Strings(holders)
+
+			keyProviders = append(keyProviders, ProviderRecord{
+				Namespace:         key,
+				PeerID:            ai.ID.String(),
+				Addrs:             addrsToStrings(ai),
+				Holders:           holders,
+				ReplicationFactor: len(holders),
+			})
+		}
+		sort.Slice(keyProviders, func(i, j int) bool { return keyProviders[i].PeerID < keyProviders[j].PeerID })
+		providers = append(providers, keyProviders...)
+	}
+
+	placement := make([]PlacementRecord, 0, len(recordKeys))
+	for _, key := range recordKeys {
+		report, err := r.PlacementReport(key, KademliaK)
+		if err != nil {
+			continue
+		}
+		placement = append(placement, PlacementRecord{
+			Namespace:  key,
+			Holders:    report.Holders(),
+			Closest:    newClosestPeerRecords(report.Closest),
+			FarHolders: newClosestPeerRecords(report.FarHolders),
+		})
+	}
+
+	finishTime := r.finishTime
+	if r.status == CrawlStatusInProgress {
+		finishTime = time.Now()
+	}
+	meta := CrawlMetadata{
+		Network:          net.String(),
+		Namespaces:       recordKeys,
+		HostID:           r.hostID.String(),
+		Status:           r.status.String(),
+		StartedAt:        r.initTime,
+		FinishedAt:       finishTime,
+		DurationMs:       finishTime.Sub(r.initTime).Milliseconds(),
+		TotalPeers:       len(succPeers) + len(failedPeers),
+		SuccPeers:        len(succPeers),
+		QueryFailedPeers: len(queryFailedPeers),
+		FailPeers:        len(failedPeers),
+		Providers:        provCounts,
+	}
+	agentVersions := make(map[string]int, len(r.agentVersionDist))
+	for k, v := range r.agentVersionDist {
+		agentVersions[k] = v
+	}
+
+	peers := make([]PeerRecord, 0, meta.TotalPeers)
+	for p, info := range succPeers {
+		status := PeerStatusSuccess
+		if _, ok := queryFailedPeers[p]; ok {
+			status = PeerStatusQueryFailed
+		}
+		record := newPeerRecord(info, status)
+		for _, failure := range queryFailures[p] {
+			record.ProviderQueryFailures = append(record.ProviderQueryFailures, ProviderQueryRecord{
+				Namespace: failure.Namespace,
+				Error:     failure.Error,
+				LatencyMs: failure.Latency.Milliseconds(),
+				Attempts:  failure.Attempts,
+				Recovered: failure.Recovered,
+			})
+		}
+		peers = append(peers, record)
+	}
+	failureCategories := make(map[string]int)
+	for p, info := range failedPeers {
+		record := newPeerRecord(info, PeerStatusFailed)
+		if failure, ok := failures[p]; ok {
+			record.FailureCategory = failure.Category.String()
+			record.Error = failure.Error
+			failureCategories[record.FailureCategory]++
+		}
+		peers = append(peers, record)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].PeerID < peers[j].PeerID })
+
+	return &CrawlSnapshot{
+		Version:           SnapshotVersion,
+		Metadata:          meta,
+		Peers:             peers,
+		Providers:         providers,
+		AgentVersions:     agentVersions,
+		FailureCategories: failureCategories,
+		Placement:         placement,
+	}
+}
+
+func newPeerRecord(info PeerInfo, status string) PeerRecord {
+	prots := make([]string, len(info.Protocols))
+	for i, prot := range info.Protocols {
+		prots[i] = string(prot)
+	}
+	return PeerRecord{
+		PeerID:          info.ID.String(),
+		Status:          status,
+		Addrs:           addrsToStrings(info.AddrInfo),
+		AgentVersion:    info.AgentVersion,
+		ProtocolVersion: info.ProtocolVersion,
+		Protocols:       prots,
+	}
+}
+
+func newClosestPeerRecords(peers []ClosestPeer) []ClosestPeerRecord {
+	records := make([]ClosestPeerRecord, len(peers))
+	for i, p := range peers {
+		records[i] = ClosestPeerRecord{
+			PeerID:      p.ID.String(),
+			CPL:         p.CPL,
+			Status:      p.Status,
+			HoldsRecord: p.HoldsRecord,
+		}
+	}
+	return records
+}
+
+func addrsToStrings(ai peer.AddrInfo) []string {
+	addrs := make([]string, len(ai.Addrs))
+	for i, addr := range ai.Addrs {
+		addrs[i] = addr.String()
+	}
+	return addrs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,381 @@
+package dht
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+type TraceFormat string
+
+func (f TraceFormat) String() string { return string(f) }
+
+const (
+	// TraceTree renders the queried peers nested under the peer that referred them
+	TraceTree TraceFormat = "tree"
+	// TraceTimeline renders every query event in the order they happened
+	TraceTimeline TraceFormat = "timeline"
+	// TraceJSON exports the queries and the events as a JSON array with one trace per lookup
+	TraceJSON TraceFormat = "json"
+)
+
+func TraceFormatFromString(format string) (TraceFormat, error) {
+	switch strings.ToLower(format) {
+	case TraceTree.String():
+		return TraceTree, nil
+	case TraceTimeline.String():
+		return TraceTimeline, nil
+	case TraceJSON.String():
+		return TraceJSON, nil
+	default:
+		return "", fmt.Errorf("unknown trace format: %q", format)
+	}
+}
+
+type TraceEventType string
+
+const (
+	TraceEventDial     TraceEventType = "dial"
+	TraceEventQuery    TraceEventType = "query"
+	TraceEventResponse TraceEventType = "response"
+	TraceEventError    TraceEventType = "error"
+	TraceEventProvider TraceEventType = "provider"
+)
+
+// errNoResponse marks the queries that never got an answer, as kad-dht only reports the dial errors
+const errNoResponse = "no response"
+
+// LookupTrace records how a provider lookup walked the DHT
+type LookupTrace struct {
+	Namespace string
+	Queries   []*TraceQuery
+	Events    []TraceEvent
+	Duration  time.Duration
+}
+
+// TraceQuery is a request sent to a single peer during the lookup
+type TraceQuery struct {
+	Peer peer.ID
+	// ReferredBy is the first peer that returned this one as a closer peer,
+	// empty if the peer came from the routing table
+	ReferredBy peer.ID
+	Sent       time.Duration
+	Latency    time.Duration
+	Closer     []peer.ID
+	Error      string
+}
+
+// TraceEvent is a single step of the lookup, with the time since the lookup started
+type TraceEvent struct {
+	Elapsed time.Duration
+	Type    TraceEventType
+	Peer    peer.ID
+	Closer  int
+	Error   string
+}
+
+// lookupTracer builds a LookupTrace out of the query events of kad-dht
+type lookupTracer struct {
+	m     sync.Mutex
+	start time.Time
+	trace *LookupTrace
+
+	dialing    map[peer.ID]time.Duration
+	pending    map[peer.ID]*TraceQuery
+	referredBy map[peer.ID]peer.ID
+}
+
+func newLookupTracer(namespace string) *lookupTracer {
+	return &lookupTracer{
+		start: time.Now(),
+		trace: &LookupTrace{
+			Namespace: namespace,
+			Queries:   make([]*TraceQuery, 0),
+			Events:    make([]TraceEvent, 0),
+		},
+		dialing:    make(map[peer.ID]time.Duration),
+		pending:    make(map[peer.ID]*TraceQuery),
+		referredBy: make(map[peer.ID]peer.ID),
+	}
+}
+
+func (t *lookupTracer) addEvent(ev *routing.QueryEvent) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	elapsed := time.Since(t.start)
+	event := TraceEvent{Elapsed: elapsed, Peer: ev.ID}
+	switch ev.Type {
+	case routing.DialingPeer:
+		event.Type = TraceEventDial
+		t.dialing[ev.ID] = elapsed
+
+	case routing.SendingQuery:
+		event.Type = TraceEventQuery
+		query := &TraceQuery{
+			Peer:       ev.ID,
+			ReferredBy: t.referredBy[ev.ID],
+			Sent:       elapsed,
+		}
+		t.pending[ev.ID] = query
+		t.trace.Queries = append(t.trace.Queries, query)
+
+	case routing.PeerResponse:
+		event.Type = TraceEventResponse
+		event.Closer = len(ev.Responses)
+		if query, ok := t.pending[ev.ID]; ok {
+			delete(t.pending, ev.ID)
+			query.Latency = elapsed - query.Sent
+			query.Closer = make([]peer.ID, 0, len(ev.Responses))
+			for _, ai := range ev.Responses {
+				query.Closer = append(query.Closer, ai.ID)
+			}
+		}
+		for _, ai := range ev.Responses {
+			if _, ok := t.referredBy[ai.ID]; !ok && ai.ID != ev.ID {
+				t.referredBy[ai.ID] = ev.ID
+			}
+		}
+
+	case routing.QueryError:
+		event.Type = TraceEventError
+		event.Error = ev.Extra
+		if ev.ID == "" {
+			break
+		}
+		query, ok := t.pending[ev.ID]
+		if ok {
+			delete(t.pending, ev.ID)
+		} else {
+			// the dial failed before the request could be sent
+			query = &TraceQuery{
+				Peer:       ev.ID,
+				ReferredBy: t.referredBy[ev.ID],
+				Sent:       t.dialing[ev.ID],
+			}
+			t.trace.Queries = append(t.trace.Queries, query)
+		}
+		query.Latency = elapsed - query.Sent
+		query.Error = ev.Extra
+
+	default:
+		return
+	}
+	t.trace.Events = append(t.trace.Events, event)
+}
+
+func (t *lookupTracer) addProvider(p FoundProvider) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.trace.Events = append(t.trace.Events, TraceEvent{
+		Elapsed: p.Elapsed,
+		Type:    TraceEventProvider,
+		Peer:    p.ID,
+	})
+}
+
+func (t *lookupTracer) finish() *LookupTrace {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.trace.Duration = time.Since(t.start)
+	for _, query := range t.pending {
+		query.Error = errNoResponse
+	}
+	return t.trace
+}
+
+// FindProvidersTraced is FindProviders recording every query that kad-dht sends during the lookup
+func FindProvidersTraced(ctx context.Context, disc discovery.Discoverer, namespace string, onProvider func(FoundProvider)) (*LookupResult, *LookupTrace, error) {
+	tracer := newLookupTracer(namespace)
+
+	evCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	evCtx, events := routing.RegisterForQueryEvents(evCtx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ev := range events {
+			tracer.addEvent(ev)
+		}
+	}()
+
+	result, err := FindProviders(evCtx, disc, namespace, func(p FoundProvider) {
+		tracer.addProvider(p)
+		if onProvider != nil {
+			onProvider(p)
+		}
+	})
+	// the events channel is closed once its context is canceled
+	cancel()
+	<-done
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, tracer.finish(), nil
+}
+
+// WriteTraces renders the traces of one or more lookups in the given format
+func WriteTraces(w io.Writer, traces []*LookupTrace, format TraceFormat) error {
+	switch format {
+	case TraceTree:
+		for _, trace := range traces {
+			if err := trace.writeTree(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TraceTimeline:
+		for _, trace := range traces {
+			if err := trace.writeTimeline(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TraceJSON:
+		records := make([]traceRecord, 0, len(traces))
+		for _, trace := range traces {
+			records = append(records, trace.record())
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	default:
+		return fmt.Errorf("unknown trace format: %q", format)
+	}
+}
+
+func (t *LookupTrace) writeTree(w io.Writer) error {
+	children := make(map[peer.ID][]*TraceQuery)
+	for _, query := range t.Queries {
+		children[query.ReferredBy] = append(children[query.ReferredBy], query)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "lookup of %s: %d queries in %s\n", t.Namespace, len(t.Queries), fmtMs(t.Duration))
+
+	// guards against referral cycles, which happen when a peer is queried more than once
+	printed := make(map[*TraceQuery]bool)
+	var walk func(parent peer.ID, prefix string)
+	walk = func(parent peer.ID, prefix string) {
+		queries := children[parent]
+		for i, query := range queries {
+			if printed[query] {
+				continue
+			}
+			printed[query] = true
+
+			branch, indent := "├── ", "│   "
+			if i == len(queries)-1 {
+				branch, indent = "└── ", "    "
+			}
+			fmt.Fprintf(&b, "%s%s%s\n", prefix, branch, query.summary())
+			walk(query.Peer, prefix+indent)
+		}
+	}
+	walk("", "")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (t *LookupTrace) writeTimeline(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "lookup of %s: %d events in %s\n", t.Namespace, len(t.Events), fmtMs(t.Duration))
+	for _, ev := range t.Events {
+		fmt.Fprintf(&b, "+%10s  %-8s  %s", fmtMs(ev.Elapsed), ev.Type, ev.Peer)
+		switch ev.Type {
+		case TraceEventResponse:
+			fmt.Fprintf(&b, "  closer=%d", ev.Closer)
+		case TraceEventError:
+			fmt.Fprintf(&b, "  %s", ev.Error)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (q *TraceQuery) summary() string {
+	s := fmt.Sprintf("%s  sent=+%s latency=%s", q.Peer, fmtMs(q.Sent), fmtMs(q.Latency))
+	if q.Error != "" {
+		return s + "  error: " + q.Error
+	}
+	return s + fmt.Sprintf("  closer=%d", len(q.Closer))
+}
+
+func fmtMs(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+type traceRecord struct {
+	Namespace  string             `json:"namespace"`
+	DurationMs float64            `json:"duration_ms"`
+	Queries    []traceQueryRecord `json:"queries"`
+	Events     []traceEventRecord `json:"events"`
+}
+
+type traceQueryRecord struct {
+	PeerID     string   `json:"peer_id"`
+	ReferredBy string   `json:"referred_by,omitempty"`
+	SentMs     float64  `json:"sent_ms"`
+	LatencyMs  float64  `json:"latency_ms"`
+	Closer     []string `json:"closer_peers"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type traceEventRecord struct {
+	ElapsedMs float64 `json:"elapsed_ms"`
+	Type      string  `json:"type"`
+	PeerID    string  `json:"peer_id,omitempty"`
+	Closer    int     `json:"closer_peers,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func (t *LookupTrace) record() traceRecord {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	rec := traceRecord{
+		Namespace:  t.Namespace,
+		DurationMs: ms(t.Duration),
+		Queries:    make([]traceQueryRecord, 0, len(t.Queries)),
+		Events:     make([]traceEventRecord, 0, len(t.Events)),
+	}
+	for _, q := range t.Queries {
+		closer := make([]string, 0, len(q.Closer))
+		for _, p := range q.Closer {
+			closer = append(closer, p.String())
+		}
+		qr := traceQueryRecord{
+			PeerID:    q.Peer.String(),
+			SentMs:    ms(q.Sent),
+			LatencyMs: ms(q.Latency),
+			Closer:    closer,
+			Error:     q.Error,
+		}
+		if q.ReferredBy != "" {
+			qr.ReferredBy = q.ReferredBy.String()
+		}
+		rec.Queries = append(rec.Queries, qr)
+	}
+	for _, ev := range t.Events {
+		er := traceEventRecord{
+			ElapsedMs: ms(ev.Elapsed),
+			Type:      string(ev.Type),
+			Closer:    ev.Closer,
+			Error:     ev.Error,
+		}
+		if ev.Peer != "" {
+			er.PeerID = ev.Peer.String()
+		}
+		rec.Events = append(rec.Events, er)
+	}
+	return rec
+}